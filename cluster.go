@@ -0,0 +1,185 @@
+package kubegen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kclient "k8s.io/client-go/kubernetes"
+)
+
+// ClusterAnnotation is set to the name of the cluster of each object when
+// objects are loaded from several clusters
+const ClusterAnnotation = "kube-gen/cluster"
+
+// ClusterConfig configures the connection to one of several clusters that
+// objects are loaded from. Only the kubeconfig files and rate limit settings
+// of the Config apply to every cluster: the credentials, TLS settings and
+// impersonation of the Config are those of its own cluster, so each cluster
+// authenticates using its kubeconfig context, in-cluster config, or the
+// credentials set here.
+type ClusterConfig struct {
+	// Name identifies the cluster in templates
+	Name string
+	// Host, if set, is the address of the API server of the cluster
+	Host string
+	// Kubeconfig is the kubeconfig file(s) of the cluster. Defaults to the
+	// Kubeconfig of the Config.
+	Kubeconfig string
+	// KubeContext is the kubeconfig context of the cluster. Defaults to the
+	// current context.
+	KubeContext        string
+	UseInClusterConfig bool
+
+	// credentials and TLS settings of the cluster, overriding those of the
+	// kubeconfig context or in-cluster config
+	BearerToken           string
+	BearerTokenFile       string
+	ClientCertificate     string
+	ClientKey             string
+	CertificateAuthority  string
+	InsecureSkipTLSVerify bool
+}
+
+// cluster is a cluster objects are loaded from
+type cluster struct {
+	name   string
+	client kclient.Interface
+}
+
+// newClusters connects to the clusters configured in g.Config.Clusters
+func (g *generator) newClusters() error {
+	names := map[string]bool{}
+	for _, cc := range g.Config.Clusters {
+		if cc.Name == "" {
+			return errors.New("clusters must have a name")
+		}
+		if names[cc.Name] {
+			return fmt.Errorf("duplicate cluster: %s", cc.Name)
+		}
+		names[cc.Name] = true
+
+		rc, err := newKubeConfig(g.clusterConfig(cc))
+		if err != nil {
+			return fmt.Errorf("error configuring cluster %s: %w", cc.Name, err)
+		}
+		client, err := newKubeClient(rc)
+		if err != nil {
+			return fmt.Errorf("error configuring cluster %s: %w", cc.Name, err)
+		}
+		g.clusters = append(g.clusters, cluster{name: cc.Name, client: client})
+	}
+	return nil
+}
+
+// clusterConfig returns the Config used to connect to the cluster configured
+// by cc, which keeps the kubeconfig files and rate limits of g.Config, but none
+// of the settings of its cluster or user
+func (g *generator) clusterConfig(cc ClusterConfig) Config {
+	c := Config{
+		Kubeconfig:            g.Config.Kubeconfig,
+		Host:                  cc.Host,
+		KubeContext:           cc.KubeContext,
+		UseInClusterConfig:    cc.UseInClusterConfig,
+		BearerToken:           cc.BearerToken,
+		BearerTokenFile:       cc.BearerTokenFile,
+		ClientCertificate:     cc.ClientCertificate,
+		ClientKey:             cc.ClientKey,
+		CertificateAuthority:  cc.CertificateAuthority,
+		InsecureSkipTLSVerify: cc.InsecureSkipTLSVerify,
+		QPS:                   g.Config.QPS,
+		Burst:                 g.Config.Burst,
+	}
+	if cc.Kubeconfig != "" {
+		c.Kubeconfig = cc.Kubeconfig
+	}
+	return c
+}
+
+// sources returns the clusters objects are loaded from. Without configured
+// clusters, objects are loaded from the cluster of the generator's client,
+// which has no name.
+func (g *generator) sources() []cluster {
+	if len(g.clusters) > 0 {
+		return g.clusters
+	}
+	return []cluster{{client: g.Client}}
+}
+
+// setCluster annotates the objects of ctx with the name of their cluster
+func (ctx *Context) setCluster(name string) {
+	for i := range ctx.Pods {
+		setClusterAnnotation(&ctx.Pods[i].ObjectMeta, name)
+	}
+	for i := range ctx.Services {
+		setClusterAnnotation(&ctx.Services[i].ObjectMeta, name)
+	}
+	for i := range ctx.Endpoints {
+		setClusterAnnotation(&ctx.Endpoints[i].ObjectMeta, name)
+	}
+}
+
+func setClusterAnnotation(m *metav1.ObjectMeta, name string) {
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	m.Annotations[ClusterAnnotation] = name
+}
+
+// addCluster adds the objects of c, the Context of the named cluster, to ctx
+func (ctx *Context) addCluster(name string, c *Context) {
+	c.setCluster(name)
+	if ctx.Clusters == nil {
+		ctx.Clusters = map[string]*Context{}
+	}
+	ctx.Clusters[name] = c
+	ctx.Pods = append(ctx.Pods, c.Pods...)
+	ctx.Services = append(ctx.Services, c.Services...)
+	ctx.Endpoints = append(ctx.Endpoints, c.Endpoints...)
+}
+
+// groupClusters sets the Clusters of a Context loaded from manifests or a
+// snapshot, using the cluster annotations of its objects. Clusters is left
+// empty if no objects are annotated.
+func (ctx *Context) groupClusters() {
+	clusters := map[string]*Context{}
+	cluster := func(m metav1.ObjectMeta) *Context {
+		name, ok := m.Annotations[ClusterAnnotation]
+		if !ok {
+			return nil
+		}
+		if clusters[name] == nil {
+			clusters[name] = &Context{}
+		}
+		return clusters[name]
+	}
+	for _, p := range ctx.Pods {
+		if c := cluster(p.ObjectMeta); c != nil {
+			c.Pods = append(c.Pods, p)
+		}
+	}
+	for _, s := range ctx.Services {
+		if c := cluster(s.ObjectMeta); c != nil {
+			c.Services = append(c.Services, s)
+		}
+	}
+	for _, e := range ctx.Endpoints {
+		if c := cluster(e.ObjectMeta); c != nil {
+			c.Endpoints = append(c.Endpoints, e)
+		}
+	}
+	if len(clusters) > 0 {
+		ctx.Clusters = clusters
+	}
+}
+
+// clusterNames returns the names of the clusters of ctx in order
+func (ctx *Context) clusterNames() []string {
+	names := make([]string, 0, len(ctx.Clusters))
+	for name := range ctx.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
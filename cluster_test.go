@@ -0,0 +1,203 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newClusterGenerator(tmpl string) *generator {
+	return &generator{
+		Config: Config{TemplateString: tmpl},
+		clusters: []cluster{
+			{name: "east", client: fake.NewSimpleClientset(
+				&kapi.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+				&kapi.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"}},
+			)},
+			{name: "west", client: fake.NewSimpleClientset(
+				&kapi.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+			)},
+		},
+		loadSvcs: true,
+	}
+}
+
+func TestLoadContextClusters(t *testing.T) {
+	g := newClusterGenerator("")
+	ctx, err := g.loadContext(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ctx.Services) != 3 {
+		t.Errorf("expected 3 services, got %d", len(ctx.Services))
+	}
+	for _, s := range ctx.Services {
+		if s.Annotations[ClusterAnnotation] == "" {
+			t.Errorf("service %s has no cluster annotation", s.Name)
+		}
+	}
+	if len(ctx.Clusters) != 2 || len(ctx.Clusters["east"].Services) != 2 || len(ctx.Clusters["west"].Services) != 1 {
+		t.Errorf("unexpected clusters: %v", ctx.Clusters)
+	}
+
+	tmpl, err := parseTemplateString(`{{ range .Clusters.west.Services }}{{ .Name }} {{ end }}` +
+		`{{ range .Services }}{{ index .Annotations "kube-gen/cluster" }}/{{ .Name }} {{ end }}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := execTemplate(tmpl, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "web east/db east/web west/web "; string(out) != expected {
+		t.Errorf("expected output [%s], got [%s]", expected, out)
+	}
+}
+
+func TestSnapshotReplayClusters(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newClusterGenerator("").Snapshot(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	snap := filepath.Join(dir, "snapshot.json.gz")
+	if err := os.WriteFile(snap, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	replay, err := NewGenerator(Config{
+		Replay:         snap,
+		TemplateString: `{{ range $name, $c := .Clusters }}{{ $name }}={{ len $c.Services }} {{ end }}`,
+		Output:         out,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := replay.Generate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "east=2 west=1 " {
+		t.Errorf("expected output [east=2 west=1 ], got [%s]", b)
+	}
+}
+
+func TestFingerprintClusters(t *testing.T) {
+	tmpl, _ := parseTemplateString(`{{ range .Clusters.east.Services }}{{ .Name }}{{ end }}`)
+	newCtx := func(name string) *Context {
+		ctx := &Context{}
+		ctx.addCluster("east", &Context{Services: []kapi.Service{{ObjectMeta: metav1.ObjectMeta{Name: name}}}})
+		return ctx
+	}
+
+	fp1, err := fingerprint(tmpl, newCtx("web"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fp2, _ := fingerprint(tmpl, newCtx("db"))
+	if fp1 == fp2 {
+		t.Errorf("changes in a cluster should affect the fingerprint of a template that reads clusters")
+	}
+}
+
+func TestWatchEventsClusters(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	g := newClusterGenerator(`{{ range .Changes }}{{ . }} {{ end }}`)
+	g.Config.Watch = true
+	g.Config.Output = out
+	g.Config.Overwrite = true
+	g.notifiers = []notifier{n}
+
+	if err := g.watchEvents(watchContext(t, g)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() > 0 })
+
+	svc := &kapi.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cache"}}
+	if _, err := g.clusters[1].client.CoreV1().Services("default").Create(context.Background(), svc, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() > 1 })
+	if b, _ := os.ReadFile(out); string(b) != "west/Service/default/cache:add " {
+		t.Errorf("expected output [west/Service/default/cache:add ], got [%s]", b)
+	}
+}
+
+func TestNewClustersErrors(t *testing.T) {
+	cases := []struct {
+		clusters []ClusterConfig
+		expected string
+	}{
+		{[]ClusterConfig{{Host: "https://east"}}, "clusters must have a name"},
+		{[]ClusterConfig{{Name: "east", Host: "https://east"}, {Name: "east", Host: "https://west"}}, "duplicate cluster: east"},
+		{[]ClusterConfig{{Name: "east", Host: "https://east", UseInClusterConfig: true}}, "error configuring cluster east: "},
+	}
+
+	for i, c := range cases {
+		g := &generator{Config: Config{Clusters: c.clusters}}
+		err := g.newClusters()
+		if err == nil || !bytes.HasPrefix([]byte(err.Error()), []byte(c.expected)) {
+			t.Errorf("case %d: expected error [%s], got [%v]", i, c.expected, err)
+		}
+	}
+}
+
+func TestClusterConfigCredentials(t *testing.T) {
+	g := &generator{Config: Config{
+		Kubeconfig:           "/etc/kube-gen/kubeconfig",
+		KubeContext:          "primary",
+		BearerToken:          "primary-token",
+		CertificateAuthority: "/etc/kube-gen/primary-ca.crt",
+		Impersonate:          "admin",
+		ImpersonateGroups:    []string{"system:masters"},
+		QPS:                  50,
+		Burst:                100,
+	}}
+
+	rc, err := newKubeConfig(g.clusterConfig(ClusterConfig{Name: "east", Host: "https://east.example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.BearerToken != "" || rc.BearerTokenFile != "" {
+		t.Errorf("the primary token was used for another cluster: %q %q", rc.BearerToken, rc.BearerTokenFile)
+	}
+	if rc.CAFile != "" || rc.Impersonate.UserName != "" || len(rc.Impersonate.Groups) > 0 {
+		t.Errorf("the primary TLS or impersonation settings were used for another cluster: %+v", rc)
+	}
+	if rc.Host != "https://east.example.com" || rc.QPS != 50 || rc.Burst != 100 {
+		t.Errorf("unexpected config: %+v", rc)
+	}
+
+	rc, err = newKubeConfig(g.clusterConfig(ClusterConfig{
+		Name:                 "west",
+		Host:                 "https://west.example.com",
+		BearerTokenFile:      "/var/run/west/token",
+		CertificateAuthority: "/var/run/west/ca.crt",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.BearerToken != "" || rc.BearerTokenFile != "/var/run/west/token" || rc.CAFile != "/var/run/west/ca.crt" {
+		t.Errorf("expected the credentials of the cluster, got %+v", rc)
+	}
+}
+
+func TestClusterConfigKubeconfig(t *testing.T) {
+	g := &generator{Config: Config{Kubeconfig: "/etc/kube-gen/kubeconfig", KubeContext: "primary", KubeCluster: "c", KubeUser: "u"}}
+	c := g.clusterConfig(ClusterConfig{Name: "east", KubeContext: "east"})
+	if c.Kubeconfig != "/etc/kube-gen/kubeconfig" || c.KubeContext != "east" || c.KubeCluster != "" || c.KubeUser != "" {
+		t.Errorf("unexpected config: %+v", c)
+	}
+	c = g.clusterConfig(ClusterConfig{Name: "east", Kubeconfig: "/etc/east.conf"})
+	if c.Kubeconfig != "/etc/east.conf" || c.KubeContext != "" {
+		t.Errorf("unexpected config: %+v", c)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+// clusterList is a flag.Value holding the clusters given by -add-cluster
+type clusterList []kubegen.ClusterConfig
+
+func (l *clusterList) String() string {
+	names := make([]string, 0, len(*l))
+	for _, c := range *l {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (l *clusterList) Set(v string) error {
+	c, err := parseCluster(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, c)
+	return nil
+}
+
+// parseCluster parses a cluster given as <name>=<context>, or as
+// <name>=<key>=<value>,... with the keys context, kubeconfig, host,
+// in-cluster, token-file, client-certificate, client-key,
+// certificate-authority and insecure-skip-tls-verify
+func parseCluster(s string) (kubegen.ClusterConfig, error) {
+	name, spec, ok := strings.Cut(s, "=")
+	if !ok || name == "" || spec == "" {
+		return kubegen.ClusterConfig{}, fmt.Errorf("expected <name>=<context> or <name>=<key>=<value>,...: %s", s)
+	}
+	c := kubegen.ClusterConfig{Name: name}
+	if !strings.Contains(spec, "=") {
+		c.KubeContext = spec
+		return c, nil
+	}
+
+	for _, kv := range strings.Split(spec, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "context":
+			c.KubeContext = v
+		case "kubeconfig":
+			c.Kubeconfig = v
+		case "host":
+			c.Host = v
+		case "in-cluster", "insecure-skip-tls-verify":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return kubegen.ClusterConfig{}, fmt.Errorf("invalid %s value for cluster %s: %w", k, name, err)
+			}
+			if k == "in-cluster" {
+				c.UseInClusterConfig = b
+			} else {
+				c.InsecureSkipTLSVerify = b
+			}
+		case "token-file":
+			c.BearerTokenFile = v
+		case "client-certificate":
+			c.ClientCertificate = v
+		case "client-key":
+			c.ClientKey = v
+		case "certificate-authority":
+			c.CertificateAuthority = v
+		default:
+			return kubegen.ClusterConfig{}, fmt.Errorf("unknown option for cluster %s: %s", name, k)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+func TestParseCluster(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected kubegen.ClusterConfig
+		err      string
+	}{
+		{"east=prod-east", kubegen.ClusterConfig{Name: "east", KubeContext: "prod-east"}, ""},
+		{"east=context=prod-east,kubeconfig=/etc/east.conf", kubegen.ClusterConfig{Name: "east", KubeContext: "prod-east", Kubeconfig: "/etc/east.conf"}, ""},
+		{"local=in-cluster=true", kubegen.ClusterConfig{Name: "local", UseInClusterConfig: true}, ""},
+		{"proxy=host=http://localhost:8001", kubegen.ClusterConfig{Name: "proxy", Host: "http://localhost:8001"}, ""},
+		{
+			"east=host=https://east.example.com,token-file=/var/run/east/token,certificate-authority=/var/run/east/ca.crt",
+			kubegen.ClusterConfig{Name: "east", Host: "https://east.example.com", BearerTokenFile: "/var/run/east/token", CertificateAuthority: "/var/run/east/ca.crt"},
+			"",
+		},
+		{
+			"west=context=west,client-certificate=west.crt,client-key=west.key,insecure-skip-tls-verify=true",
+			kubegen.ClusterConfig{Name: "west", KubeContext: "west", ClientCertificate: "west.crt", ClientKey: "west.key", InsecureSkipTLSVerify: true},
+			"",
+		},
+		{"east=insecure-skip-tls-verify=maybe", kubegen.ClusterConfig{}, `invalid insecure-skip-tls-verify value for cluster east: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{"east", kubegen.ClusterConfig{}, "expected <name>=<context> or <name>=<key>=<value>,...: east"},
+		{"=prod-east", kubegen.ClusterConfig{}, "expected <name>=<context> or <name>=<key>=<value>,...: =prod-east"},
+		{"east=user=admin", kubegen.ClusterConfig{}, "unknown option for cluster east: user"},
+		{"east=in-cluster=maybe", kubegen.ClusterConfig{}, `invalid in-cluster value for cluster east: strconv.ParseBool: parsing "maybe": invalid syntax`},
+	}
+
+	for _, c := range cases {
+		got, err := parseCluster(c.input)
+		if err != nil {
+			if err.Error() != c.err {
+				t.Errorf("%s: expected error [%s], got [%v]", c.input, c.err, err)
+			}
+			continue
+		}
+		if c.err != "" {
+			t.Errorf("%s: expected error [%s], got none", c.input, c.err)
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: expected %+v, got %+v", c.input, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const envPrefix = "KUBEGEN_"
+
+// separators of the values of repeatable flags set from the environment.
+// Header values and clusters may contain commas, so they are separated by
+// newlines.
+var envListSeparators = map[string]string{
+	"notify-header": "\n",
+	"add-cluster":   "\n",
+}
+
+// flags that aren't read from the environment
+var envIgnored = map[string]bool{
+	"version": true,
+}
+
+const envUsage = `
+Environment:
+  Options may also be set using environment variables named KUBEGEN_ followed
+  by the option name in upper case, with - replaced by _, e.g. KUBEGEN_WAIT or
+  KUBEGEN_NOTIFY_URL. Options given on the command line take precedence over
+  the environment, which takes precedence over the config file. Repeatable
+  options are separated by commas, except for KUBEGEN_NOTIFY_HEADER and
+  KUBEGEN_ADD_CLUSTER, which are separated by newlines
+
+Config file:
+  -config (or KUBEGEN_CONFIG) reads options from a YAML or JSON object mapping
+  option names to values, e.g. {"node": "node-a", "type": ["pods"]}. Repeatable
+  options may be given a list of values
+`
+
+// envName returns the name of the environment variable for a flag
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// parseFlags adds the -config flag to fs and parses args, then sets any flags
+// that weren't given in args from the environment, and any that still aren't
+// set from the config file, exiting if any of them is invalid
+func parseFlags(fs *flag.FlagSet, args []string) {
+	config := fs.String("config", "", "path of a YAML or JSON file of options. Options given on the command line or in the environment take precedence")
+	//nolint:errcheck // ExitOnError is set, so no need to check the return value
+	fs.Parse(args)
+	err := setFlagsFromEnv(fs, os.LookupEnv)
+	if err == nil && *config != "" {
+		err = setFlagsFromConfigFile(fs, *config)
+	}
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// setFlagsFromEnv sets the flags of fs that haven't been set from the
+// environment variables returned by lookup
+func setFlagsFromEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || envIgnored[f.Name] {
+			return
+		}
+		name := envName(f.Name)
+		v, ok := lookup(name)
+		if !ok {
+			return
+		}
+
+		values := []string{v}
+		switch f.Value.(type) {
+		case *stringSlice, *clusterList:
+			sep, ok := envListSeparators[f.Name]
+			if !ok {
+				sep = ","
+			}
+			values = nil
+			for _, s := range strings.Split(v, sep) {
+				if s = strings.TrimSpace(s); s != "" {
+					values = append(values, s)
+				}
+			}
+		}
+		for _, s := range values {
+			if serr := fs.Set(f.Name, s); serr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", v, name, serr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// setFlagsFromConfigFile sets the flags of fs that haven't been set from the
+// config file at path
+func setFlagsFromConfigFile(fs *flag.FlagSet, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if err := setFlagsFromConfig(fs, b); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setFlagsFromConfig sets the flags of fs that haven't been set from the YAML
+// or JSON object in b, whose keys are flag names. Repeatable flags may be
+// given a list of values
+func setFlagsFromConfig(fs *flag.FlagSet, b []byte) error {
+	b, err := kyaml.ToJSON(b)
+	if err != nil {
+		return err
+	}
+	var options map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&options); err != nil {
+		return fmt.Errorf("expected an object of options: %w", err)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || name == "config" || envIgnored[name] {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if set[name] {
+			continue
+		}
+
+		var values []interface{}
+		switch v := options[name].(type) {
+		case []interface{}:
+			switch f.Value.(type) {
+			case *stringSlice, *clusterList:
+			default:
+				return fmt.Errorf("option %q can't be given a list", name)
+			}
+			values = v
+		default:
+			values = []interface{}{v}
+		}
+		for _, v := range values {
+			var s string
+			switch v := v.(type) {
+			case string:
+				s = v
+			case bool, json.Number:
+				s = fmt.Sprint(v)
+			default:
+				return fmt.Errorf("invalid value for option %q: expected a string, number or boolean", name)
+			}
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("invalid value %q for option %q: %w", s, name, err)
+			}
+		}
+	}
+	return nil
+}
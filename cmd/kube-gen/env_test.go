@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	type values struct {
+		Node    string
+		Wait    time.Duration
+		Watch   bool
+		Types   stringSlice
+		Headers stringSlice
+	}
+	cases := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected values
+		err      error
+	}{
+		{
+			name:     "defaults",
+			expected: values{Node: "default"},
+		},
+		{
+			name: "env",
+			env: map[string]string{
+				"KUBEGEN_NODE":          "node-a",
+				"KUBEGEN_WAIT":          "5s",
+				"KUBEGEN_WATCH":         "true",
+				"KUBEGEN_TYPE":          "pods, services,",
+				"KUBEGEN_NOTIFY_HEADER": "Accept: a, b\nX-Token: c",
+			},
+			expected: values{
+				Node:    "node-a",
+				Wait:    5 * time.Second,
+				Watch:   true,
+				Types:   stringSlice{"pods", "services"},
+				Headers: stringSlice{"Accept: a, b", "X-Token: c"},
+			},
+		},
+		{
+			name: "flags take precedence",
+			args: []string{"-node", "node-b", "-type", "endpoints", "-watch=false"},
+			env: map[string]string{
+				"KUBEGEN_NODE":  "node-a",
+				"KUBEGEN_TYPE":  "pods,services",
+				"KUBEGEN_WATCH": "true",
+				"KUBEGEN_WAIT":  "5s",
+			},
+			expected: values{Node: "node-b", Wait: 5 * time.Second, Types: stringSlice{"endpoints"}},
+		},
+		{
+			name:     "empty value",
+			env:      map[string]string{"KUBEGEN_NODE": ""},
+			expected: values{},
+		},
+		{
+			name:     "unprefixed names are ignored",
+			env:      map[string]string{"NODE": "node-a", "KUBEGEN_node": "node-b"},
+			expected: values{Node: "default"},
+		},
+		{
+			name: "invalid value",
+			env:  map[string]string{"KUBEGEN_WAIT": "soon"},
+			err:  errors.New(`invalid value "soon" for KUBEGEN_WAIT: parse error`),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var v values
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.StringVar(&v.Node, "node", "default", "")
+			fs.DurationVar(&v.Wait, "wait", 0, "")
+			fs.BoolVar(&v.Watch, "watch", false, "")
+			fs.Var(&v.Types, "type", "")
+			fs.Var(&v.Headers, "notify-header", "")
+			if err := fs.Parse(c.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := setFlagsFromEnv(fs, func(name string) (string, bool) {
+				s, ok := c.env[name]
+				return s, ok
+			})
+			if (err == nil) != (c.err == nil) || (err != nil && err.Error() != c.err.Error()) {
+				t.Fatalf("got error [%v] expected [%v]", err, c.err)
+			}
+			if c.err == nil && !reflect.DeepEqual(v, c.expected) {
+				t.Errorf("got %+v expected %+v", v, c.expected)
+			}
+		})
+	}
+}
+
+func TestSetFlagsFromConfig(t *testing.T) {
+	type values struct {
+		Node     string
+		Wait     time.Duration
+		Watch    bool
+		QPS      float64
+		Types    stringSlice
+		Clusters clusterList
+	}
+	cases := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		config   string
+		expected values
+		err      error
+	}{
+		{
+			name: "yaml",
+			config: `
+node: node-a
+wait: 5s
+watch: true
+qps: 2.5
+type: [pods, services]
+add-cluster:
+  - east=context=east
+`,
+			expected: values{
+				Node:     "node-a",
+				Wait:     5 * time.Second,
+				Watch:    true,
+				QPS:      2.5,
+				Types:    stringSlice{"pods", "services"},
+				Clusters: clusterList{{Name: "east", KubeContext: "east"}},
+			},
+		},
+		{
+			name:     "json",
+			config:   `{"node": "node-a", "type": "pods"}`,
+			expected: values{Node: "node-a", Types: stringSlice{"pods"}},
+		},
+		{
+			name:     "flag > env > config file",
+			args:     []string{"-node", "node-b"},
+			env:      map[string]string{"KUBEGEN_NODE": "node-c", "KUBEGEN_TYPE": "endpoints"},
+			config:   "node: node-a\ntype: [pods]\nwait: 5s",
+			expected: values{Node: "node-b", Wait: 5 * time.Second, Types: stringSlice{"endpoints"}},
+		},
+		{
+			name:     "empty",
+			config:   "",
+			expected: values{Node: "default"},
+		},
+		{
+			name:   "unknown option",
+			config: "nodes: node-a",
+			err:    errors.New(`unknown option "nodes"`),
+		},
+		{
+			name:   "list for a single value",
+			config: "node: [node-a, node-b]",
+			err:    errors.New(`option "node" can't be given a list`),
+		},
+		{
+			name:   "object value",
+			config: "node: {name: node-a}",
+			err:    errors.New(`invalid value for option "node": expected a string, number or boolean`),
+		},
+		{
+			name:   "invalid value",
+			config: "wait: soon",
+			err:    errors.New(`invalid value "soon" for option "wait": parse error`),
+		},
+		{
+			name:   "not an object",
+			config: "- node-a",
+			err:    errors.New("expected an object of options: json: cannot unmarshal array into Go value of type map[string]interface {}"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var v values
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.StringVar(&v.Node, "node", "default", "")
+			fs.DurationVar(&v.Wait, "wait", 0, "")
+			fs.BoolVar(&v.Watch, "watch", false, "")
+			fs.Float64Var(&v.QPS, "qps", 0, "")
+			fs.Var(&v.Types, "type", "")
+			fs.Var(&v.Clusters, "add-cluster", "")
+			if err := fs.Parse(c.args); err != nil {
+				t.Fatal(err)
+			}
+			err := setFlagsFromEnv(fs, func(name string) (string, bool) {
+				s, ok := c.env[name]
+				return s, ok
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = setFlagsFromConfig(fs, []byte(c.config))
+			if (err == nil) != (c.err == nil) || (err != nil && err.Error() != c.err.Error()) {
+				t.Fatalf("got error [%v] expected [%v]", err, c.err)
+			}
+			if c.err == nil && !reflect.DeepEqual(v, c.expected) {
+				t.Errorf("got %+v expected %+v", v, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+// addConnectionFlags adds the flags used to connect to the API server
+func addConnectionFlags(fs *flag.FlagSet) {
+	fs.StringVar(&host, "host", "", "If not set will use kubeconfig. If using proxy - set it to http://localhost:8001")
+	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
+		fs.StringVar(&kubeconfig, "kubeconfig", kubeconfigEnv, "(optional) environment variable for the kubeconfig file")
+	} else if home := homeDir(); home != "" {
+		fs.StringVar(&kubeconfig, "kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file. "+
+			"Multiple files separated by "+string(filepath.ListSeparator)+" are merged")
+	} else {
+		fs.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
+	}
+	fs.StringVar(&kubeContext, "context", "", "name of the kubeconfig context to use. Defaults to the current context")
+	fs.StringVar(&kubeCluster, "cluster", "", "name of the kubeconfig cluster to use instead of the cluster of the context")
+	fs.StringVar(&kubeUser, "user", "", "name of the kubeconfig user to use instead of the user of the context")
+	fs.BoolVar(&inCluster, "in-cluster", false, "use inClusterConfig for k8s config")
+	fs.StringVar(&token, "token", "", "bearer token to authenticate to the API server with. Prefer -token-file, as "+
+		"arguments are visible to other processes")
+	fs.StringVar(&tokenFile, "token-file", "", "path to a file containing a bearer token to authenticate to the API server "+
+		"with. The file is read again periodically, so the token may be rotated")
+	fs.StringVar(&clientCert, "client-certificate", "", "path to a client certificate to authenticate to the API server "+
+		"with. Requires -client-key")
+	fs.StringVar(&clientKey, "client-key", "", "path to the key of the -client-certificate")
+	fs.StringVar(&caFile, "certificate-authority", "", "path to a CA bundle used to verify the certificate of the API server")
+	fs.BoolVar(&insecure, "insecure-skip-tls-verify", false, "don't verify the certificate of the API server. "+
+		"This is insecure, and should only be used for testing")
+	fs.StringVar(&asUser, "as", "", "user to impersonate")
+	fs.Var(&asGroups, "as-group", "group to impersonate - May be specified multiple times. Requires -as")
+	fs.Float64Var(&qps, "qps", 0, "maximum number of requests per second to the API server. Defaults to 5")
+	fs.IntVar(&burst, "burst", 0, "maximum burst of requests to the API server. Defaults to 10")
+	fs.Var(&clusters, "add-cluster", "<name>=<context> or <name>=<key>=<value>,... - also load objects from another cluster, "+
+		"available to templates as .Clusters.<name>. Keys are context, kubeconfig, host, in-cluster, token-file, "+
+		"client-certificate, client-key, certificate-authority and insecure-skip-tls-verify. The credential and TLS "+
+		"options above don't apply to added clusters - May be specified multiple times")
+}
+
+// setConnectionConfig sets the connection options of c from the flags added
+// by addConnectionFlags. The options apply to kubeconfig, in-cluster and -host
+// connections alike.
+func setConnectionConfig(c *kubegen.Config) {
+	c.Host = host
+	c.Kubeconfig = kubeconfig
+	c.KubeContext = kubeContext
+	c.KubeCluster = kubeCluster
+	c.KubeUser = kubeUser
+	c.UseInClusterConfig = inCluster
+	c.BearerToken = token
+	c.BearerTokenFile = tokenFile
+	c.ClientCertificate = clientCert
+	c.ClientKey = clientKey
+	c.CertificateAuthority = caFile
+	c.InsecureSkipTLSVerify = insecure
+	c.Impersonate = asUser
+	c.ImpersonateGroups = asGroups
+	c.QPS = float32(qps)
+	c.Burst = burst
+	c.Clusters = clusters
+}
+
+// addSelectionFlags adds the flags selecting the objects that are loaded
+func addSelectionFlags(fs *flag.FlagSet) {
+	fs.Var(&types, "type", "types of resources to pull [pods, services, endpoints] - May be specified multiple times. "+
+		"If not specified, all types will be returned")
+	fs.StringVar(&node, "node", "", "If specified, only watch pods on the specified node. "+
+		"If not specified, watch pods in the whole cluster")
+}
+
+// addOfflineFlags adds the flags loading objects from files instead of the API
+// server
+func addOfflineFlags(fs *flag.FlagSet) {
+	fs.Var(&manifests, "from-manifests", "render objects from YAML or JSON manifests instead of the API server. May be a "+
+		"file, a directory, or - to read from STDIN, and may be specified multiple times. Cannot be combined with watch mode")
+	fs.StringVar(&replay, "replay", "", "render the objects in a file written by kube-gen snapshot instead of loading them "+
+		"from the API server. Cannot be combined with watch mode")
+}
+
+// addRenderFlags adds the flags controlling the output, commands and
+// notifications of a render
+func addRenderFlags(fs *flag.FlagSet) {
+	fs.StringVar(&preCmd, "pre-cmd", "", "command to run before template generation")
+	fs.StringVar(&postCmd, "post-cmd", "", "command to run after template generation in complete")
+	fs.DurationVar(&preTimeout, "pre-cmd-timeout", 0, "maximum time the pre command may run before it is killed. "+
+		"If not specified, there is no timeout")
+	fs.DurationVar(&postTimeout, "post-cmd-timeout", 0, "maximum time the post command may run before it is killed. "+
+		"If not specified, there is no timeout")
+	fs.StringVar(&notifySignal, "notify-signal", "", "signal to send to the process given by -notify-pidfile or -notify-process "+
+		"after the output is written (default HUP)")
+	fs.StringVar(&notifyPID, "notify-pidfile", "", "path to a pid file identifying a process to signal after the output is written")
+	fs.StringVar(&notifyProc, "notify-process", "", "name of a process to signal after the output is written. All processes with "+
+		"a matching name are signaled")
+	fs.Var(&notifyURLs, "notify-url", "URL to send an HTTP request to after the output is written - May be specified multiple times")
+	fs.StringVar(&notifyMethod, "notify-method", "POST", "HTTP method used for -notify-url requests. The method is a "+
+		"template executed against the render result")
+	fs.Var(&notifyHdrs, "notify-header", "<name>: <value> - header to add to -notify-url requests. The value is a template "+
+		"executed against the render result - May be specified multiple times")
+	fs.StringVar(&notifyBody, "notify-body", "", "template for the body of -notify-url requests, executed against the render "+
+		"result (.Output, .Checksum, .Time). If not specified, the render result is sent as JSON")
+	fs.IntVar(&notifyRetry, "notify-retries", 3, "number of times to retry a -notify-url request that failed to connect, or got a 408, 429 or 5xx response")
+	fs.DurationVar(&notifyDelay, "notify-backoff", time.Second, "time to wait before the first retry of a failed -notify-url "+
+		"request. Doubles after each attempt")
+	fs.StringVar(&notifyExec, "notify-exec", "", "command to run in a container using the Kubernetes exec API after the output "+
+		"is written. Runs in the current pod unless -notify-exec-selector is set. The command is split into arguments like a "+
+		"shell would, honoring quotes and backslashes, but is not run through a shell")
+	fs.StringVar(&execCtr, "notify-exec-container", "", "container to run the -notify-exec command in. May be omitted for "+
+		"single-container pods")
+	fs.StringVar(&execSelector, "notify-exec-selector", "", "label selector - run the -notify-exec command in all running pods "+
+		"matching the selector instead of the current pod")
+	fs.StringVar(&execNS, "notify-exec-namespace", "", "namespace of the pods to run the -notify-exec command in. Defaults to "+
+		"the namespace of the current pod")
+	fs.DurationVar(&execTimeout, "notify-exec-timeout", time.Minute, "maximum time the -notify-exec command may run in each pod")
+	fs.BoolVar(&changeOnly, "notify-on-change-only", false, "only run the pre/post commands and notifications when the "+
+		"rendered content differs from the current output (default true in watch mode)")
+	fs.BoolVar(&logCmdOutput, "log-cmd", true, "log the output of the pre/post commands")
+	fs.BoolVar(&overwrite, "overwrite", true, "overwrite the output file if it exists")
+}
+
+// addWatchFlags adds the flags used in watch mode
+func addWatchFlags(fs *flag.FlagSet) {
+	fs.StringVar(&wait, "wait", "", "<minimum>[:<maximum>] - the minimum and optional maximum time to wait after an event fires. "+
+		"E.g.: 500ms:5s")
+	fs.BoolVar(&waitLeading, "wait-leading", false, "render on the first event after a quiet period instead of waiting "+
+		"for the -wait minimum. Events received during the -wait period are rendered once it ends")
+	fs.IntVar(&interval, "interval", 0, "also render every <interval> seconds, even if no events were received. As with "+
+		"events, the output only changes if data read by the template changed, or the template uses shell, exists or dir. "+
+		"Disabled if 0")
+	fs.DurationVar(&syncTimeout, "sync-timeout", time.Minute, "maximum time to wait for the initial list of resources to "+
+		"load before failing")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "address to serve Prometheus metrics on, e.g. :9090. "+
+		"Metrics are disabled if not specified")
+	fs.StringVar(&healthAddr, "health-addr", "", "address to serve /healthz and /readyz on, e.g. :8080. "+
+		"May be the same as -metrics-addr. Health checks are disabled if not specified")
+	fs.DurationVar(&renderLimit, "health-render-timeout", 5*time.Minute, "time a single render (including pre/post "+
+		"commands) may run before /healthz reports a failure")
+	fs.BoolVar(&leaderElect, "leader-elect", false, "elect a leader using a coordination.k8s.io Lease. Only "+
+		"the leader renders the template and runs commands and notifications")
+	fs.StringVar(&leaderNS, "leader-elect-namespace", "", "namespace of the leader election Lease. Defaults to the "+
+		"namespace of the current pod")
+	fs.StringVar(&leaderName, "leader-elect-name", "kube-gen", "name of the leader election Lease")
+	fs.StringVar(&leaderID, "leader-elect-id", "", "identity of this replica in the leader election. Defaults to the hostname")
+	fs.DurationVar(&leaseTime, "leader-elect-lease-duration", 15*time.Second, "time followers wait before taking over "+
+		"a lease that has not been renewed")
+	fs.DurationVar(&renewTime, "leader-elect-renew-deadline", 10*time.Second, "time the leader keeps retrying to renew "+
+		"the lease before giving up leadership")
+	fs.DurationVar(&retryTime, "leader-elect-retry-period", 2*time.Second, "time to wait between attempts to acquire "+
+		"or renew the lease")
+}
+
+// addLogFlags adds the flags configuring the logger
+func addLogFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&quiet, "quiet", false, "when set to true, nothing is logged")
+	fs.StringVar(&logLevel, "log-level", "info", "minimum level of messages to log [debug, info, warn, error]")
+	fs.StringVar(&logFormat, "log-format", "text", "format of log messages [text, json]")
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	var set bool
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
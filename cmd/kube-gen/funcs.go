@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func funcsUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Printf(`Usage: kube-gen funcs
+
+List the functions available in templates, in addition to the functions built
+into text/template
+
+`)
+		fs.PrintDefaults()
+	}
+}
+
+// runFuncs runs the funcs command, returning the exit code
+func runFuncs(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("funcs", flag.ExitOnError)
+	fs.Usage = funcsUsage(fs)
+	//nolint:errcheck // ExitOnError is set, so no need to check the return value
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	names := make([]string, 0, len(kubegen.Funcs))
+	for name := range kubegen.Funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", funcSignature(name, kubegen.Funcs[name]), kubegen.FuncDescriptions[name])
+	}
+	if err := tw.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// funcSignature formats the signature of a template function. A trailing
+// error result is omitted, as templates fail instead of receiving it.
+func funcSignature(name string, f any) string {
+	t := reflect.TypeOf(f)
+	params := make([]string, t.NumIn())
+	for i := range params {
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			params[i] = "..." + typeName(t.In(i).Elem())
+		} else {
+			params[i] = typeName(t.In(i))
+		}
+	}
+	sig := name + "(" + strings.Join(params, ", ") + ")"
+	if t.NumOut() > 0 && t.Out(0) != errorType {
+		sig += " " + typeName(t.Out(0))
+	}
+	return sig
+}
+
+func typeName(t reflect.Type) string {
+	return strings.ReplaceAll(t.String(), "interface {}", "any")
+}
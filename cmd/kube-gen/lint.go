@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+func lintUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Printf(`Usage: kube-gen lint [options] <template>...
+
+Check templates for syntax errors, unknown functions, and references to fields
+that don't exist. Fields are only checked where their type is known, e.g. not
+in the results of functions returning arbitrary values
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Print(envUsage)
+	}
+}
+
+// runLint runs the lint command, returning the exit code
+func runLint(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = lintUsage(fs)
+	parseFlags(fs, args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		diags, err := kubegen.LintTemplateFile(path)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			code = 1
+			continue
+		}
+		for _, d := range diags {
+			fmt.Fprintln(w, d)
+			code = 1
+		}
+	}
+	return code
+}
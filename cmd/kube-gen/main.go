@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	kubegen "github.com/kylemcc/kube-gen"
@@ -22,20 +25,63 @@ var (
 	// flags
 	host         string
 	kubeconfig   string
+	kubeContext  string
+	kubeCluster  string
+	kubeUser     string
+	token        string
+	tokenFile    string
+	clientCert   string
+	clientKey    string
+	caFile       string
+	insecure     bool
+	asUser       string
+	asGroups     stringSlice
+	qps          float64
+	burst        int
+	clusters     clusterList
 	types        stringSlice
-	watch        bool
+	manifests    stringSlice
+	replay       string
 	preCmd       string
 	postCmd      string
+	preTimeout   time.Duration
+	postTimeout  time.Duration
 	logCmdOutput bool
+	changeOnly   bool
 	overwrite    bool
 	wait         string
+	waitLeading  bool
 	interval     int
 	quiet        bool
-	showVersion  bool
+	logLevel     string
+	logFormat    string
 	inCluster    bool
 	node         string
-
-	flags = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	metricsAddr  string
+	healthAddr   string
+	renderLimit  time.Duration
+	syncTimeout  time.Duration
+	notifySignal string
+	notifyPID    string
+	notifyProc   string
+	notifyURLs   stringSlice
+	notifyMethod string
+	notifyHdrs   stringSlice
+	notifyBody   string
+	notifyRetry  int
+	notifyDelay  time.Duration
+	notifyExec   string
+	execCtr      string
+	execSelector string
+	execNS       string
+	execTimeout  time.Duration
+	leaderElect  bool
+	leaderNS     string
+	leaderName   string
+	leaderID     string
+	leaseTime    time.Duration
+	renewTime    time.Duration
+	retryTime    time.Duration
 )
 
 // implement flag.Value interface
@@ -48,53 +94,161 @@ func (s *stringSlice) Set(v string) error {
 	return nil
 }
 
+// commands are the subcommands of kube-gen, other than help
+var commands = []struct {
+	name    string
+	summary string
+	run     func(args []string, w io.Writer) int
+}{
+	{"render", "render a template once", runRender},
+	{"watch", "render a template, and render it again when resources change", runWatch},
+	{"snapshot", "write the objects a render would load to a file", runSnapshot},
+	{"test", "run template test cases", runTemplateTests},
+	{"lint", "check templates for errors", runLint},
+	{"funcs", "list the functions available in templates", runFuncs},
+	{"serve-playground", "serve a web page for editing templates and viewing their output", runPlayground},
+	{"version", "display version information", runVersion},
+}
+
 func usage() {
-	fmt.Printf(`Usage: kube-gen [options] <template> [<output>]
+	fmt.Printf(`Usage: kube-gen <command> [options] [<arguments>]
 
 Render templates using Kubernetes metadata and events
 
-Options:
+Commands:
 `)
-	flags.PrintDefaults()
+	for _, c := range commands {
+		fmt.Printf("  %-17s %s\n", c.name, c.summary)
+	}
+	fmt.Printf(`  %-17s display help for a command
+
+Run kube-gen help <command> for the options and arguments of a command.
+
+For compatibility with earlier versions, a template may also be rendered
+without a command using kube-gen [options] <template> [<output>], which accepts
+the options of both render and watch, as well as -watch to watch for changes
+and -version to display version information.
+`, "help")
+}
 
-	fmt.Printf(`
+const templateArgsUsage = `
 Arguments:
   template: path or URL of the template file to render, or - to read from STDIN
   output: (Optional) path to write the rendered content. If not specified,
           rendered content is printed to STDOUT. By default, this file will
           be overwritten if it exists. Use -overwrite=false to return an
-          error instead
-`)
+          error instead. Output may also be written to a key of a ConfigMap
+          or Secret using configmap://<namespace>/<name>/<key> or
+          secret://<namespace>/<name>/<key>
+`
+
+func renderUsage(fs *flag.FlagSet, name, description string) func() {
+	return func() {
+		fmt.Printf("Usage: kube-gen %s [options] <template> [<output>]\n\n%s\n\nOptions:\n", name, description)
+		fs.PrintDefaults()
+		fmt.Print(templateArgsUsage)
+		fmt.Print(envUsage)
+	}
 }
 
-func parseFlags() {
-	flags.StringVar(&host, "host", "", "If not set will use kubeconfig. If using proxy - set it to http://localhost:8001")
-	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-		flags.StringVar(&kubeconfig, "kubeconfig", kubeconfigEnv, "(optional) environment variable for the kubeconfig file")
-	} else if home := homeDir(); home != "" {
-		flags.StringVar(&kubeconfig, "kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		flags.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flags.Var(&types, "type", "types of resources to pull [pods, services, endpoints] - May be specified multiple times. "+
-		"If not specified, all types will be returned")
-	flags.BoolVar(&showVersion, "version", false, "display version information")
-	flags.BoolVar(&watch, "watch", false, "watch for new events")
-	flags.StringVar(&node, "node", os.Getenv("KUBEGEN_NODE"), "If specified, only watch pods on the specified node. "+
-		"If not specified, watch pods in the whole cluster. May also be set using the KUBEGEN_NODE environment variable.")
-	flags.StringVar(&preCmd, "pre-cmd", "", "command to run before template generation")
-	flags.StringVar(&postCmd, "post-cmd", "", "command to run after template generation in complete")
-	flags.BoolVar(&logCmdOutput, "log-cmd", true, "log the output of the pre/post commands")
-	flags.BoolVar(&overwrite, "overwrite", true, "overwrite the output file if it exists")
-	flags.StringVar(&wait, "wait", "", "<minimum>[:<maximum>] - the minimum and optional maximum time to wait after an event fires."+
-		"E.g.: 500ms:5s")
-	flags.IntVar(&interval, "interval", 0, "")
-	flags.BoolVar(&quiet, "quiet", false, "when set to true, nothing is logged")
-	flags.BoolVar(&inCluster, "in-cluster", false, "use inClusterConfig for k8s config")
-	flags.Usage = usage
+// run runs the command given by args, returning the exit code
+func run(args []string, w io.Writer) int {
+	if len(args) == 0 {
+		usage()
+		return 1
+	}
+	if args[0] == "help" {
+		return runHelp(args[1:], w)
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], w)
+		}
+	}
+	return runLegacy(args)
+}
 
+// runHelp prints the usage of the command given as the only argument
+func runHelp(args []string, w io.Writer) int {
+	if len(args) == 0 {
+		usage()
+		return 0
+	}
+	for _, c := range commands {
+		if len(args) == 1 && c.name == args[0] {
+			// -h prints the usage and exits
+			return c.run([]string{"-h"}, w)
+		}
+	}
+	fmt.Fprintf(w, "unknown command: %s\n", strings.Join(args, " "))
+	return 2
+}
+
+// runRender runs the render command, which renders the template once
+func runRender(args []string, _ io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	addConnectionFlags(fs)
+	addSelectionFlags(fs)
+	addOfflineFlags(fs)
+	addRenderFlags(fs)
+	addLogFlags(fs)
+	fs.Usage = renderUsage(fs, "render", "Render a template once using Kubernetes metadata")
+	parseFlags(fs, args)
+	return generate(fs, false)
+}
+
+// runWatch runs the watch command, which renders the template, and renders it
+// again when resources change until a signal is received
+func runWatch(args []string, _ io.Writer) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	addConnectionFlags(fs)
+	addSelectionFlags(fs)
+	addRenderFlags(fs)
+	addWatchFlags(fs)
+	addLogFlags(fs)
+	fs.Usage = renderUsage(fs, "watch", `Render a template using Kubernetes metadata, and render it again when the
+resources it uses change. Send SIGHUP to force a render, and SIGINT, SIGQUIT or
+SIGTERM to stop watching`)
+	parseFlags(fs, args)
+	return generate(fs, true)
+}
+
+// runLegacy renders a template using the flags of both render and watch,
+// which is how kube-gen was invoked before it had commands
+func runLegacy(args []string) int {
+	fs := flag.NewFlagSet("kube-gen", flag.ExitOnError)
+	addConnectionFlags(fs)
+	addSelectionFlags(fs)
+	addOfflineFlags(fs)
+	addRenderFlags(fs)
+	addWatchFlags(fs)
+	addLogFlags(fs)
+	var watch, showVersion bool
+	fs.BoolVar(&watch, "watch", false, "watch for new events")
+	fs.BoolVar(&showVersion, "version", false, "display version information")
+	fs.Usage = usage
+	parseFlags(fs, args)
+	if showVersion {
+		printVersion(os.Stdout)
+		return 0
+	}
+	return generate(fs, watch)
+}
+
+// runVersion runs the version command
+func runVersion(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: kube-gen version\n\nDisplay version information\n")
+	}
 	//nolint:errcheck // ExitOnError is set, so no need to check the return value
-	flags.Parse(os.Args[1:])
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	printVersion(w)
+	return 0
 }
 
 func homeDir() string {
@@ -104,8 +258,8 @@ func homeDir() string {
 	return os.Getenv("USERPROFILE") // windows
 }
 
-func printVersion() {
-	fmt.Printf(`version:  %s
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, `version:  %s
 built at: %s
 revision: %s
 runtime:  %s
@@ -132,69 +286,155 @@ func parseWait(w string) (minWait time.Duration, maxWait time.Duration, err erro
 	return
 }
 
+// newLogger creates a logger writing to w in the given format (text or json)
+// at the given level. Nothing is logged when quiet is set.
+func newLogger(w io.Writer, level, format string, quiet bool) (*slog.Logger, error) {
+	if quiet {
+		w = io.Discard
+	}
+
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %s", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format: %s", format)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func tmplFromStdin() ([]byte, error) {
 	return io.ReadAll(os.Stdin)
 }
 
 func main() {
-	parseFlags()
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
 
-	if quiet {
-		log.SetOutput(io.Discard)
+// setupLogging configures the default logger using the logging flags
+func setupLogging() {
+	logger, err := newLogger(os.Stderr, logLevel, logFormat, quiet)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
+}
 
-	if showVersion {
-		printVersion()
-		return
+// generate renders the template given by the arguments of fs
+func generate(fs *flag.FlagSet, watch bool) int {
+	if !isFlagSet(fs, "notify-on-change-only") {
+		changeOnly = watch
 	}
+	setupLogging()
 
-	if narg := flags.NArg(); narg < 1 || narg > 2 {
-		flags.Usage()
-		os.Exit(1)
+	if narg := fs.NArg(); narg < 1 || narg > 2 {
+		fs.Usage()
+		return 1
 	}
 
 	minWait, maxWait, err := parseWait(wait)
 	if err != nil {
-		log.Fatalf("invalid wait value: %v", err)
+		fatal("invalid wait value", err)
 	}
 
 	var tmplStr string
-	if flags.Arg(0) == "-" {
-		log.Printf("reading template from stdin")
+	if fs.Arg(0) == "-" {
+		if slices.Contains(manifests, "-") {
+			fatal("invalid arguments", errors.New("the template and manifests cannot both be read from stdin"))
+		}
+		slog.Info("reading template from stdin")
 		if s, err := tmplFromStdin(); err != nil {
-			log.Fatalf("error reading from stdin: %v", err)
+			fatal("error reading from stdin", err)
 		} else {
 			tmplStr = strings.TrimSpace(string(s))
 		}
 	}
-	if flags.Arg(1) == "" {
-		log.Printf("writing output to stdout")
+	if fs.Arg(1) == "" {
+		slog.Info("writing output to stdout")
 	}
 
 	conf := kubegen.Config{
-		Host:               host,
-		Kubeconfig:         kubeconfig,
-		TemplateString:     tmplStr,
-		TemplatePath:       flags.Arg(0),
-		Output:             flags.Arg(1),
-		Overwrite:          overwrite,
-		Watch:              watch,
-		PreCmd:             preCmd,
-		PostCmd:            postCmd,
-		ResourceTypes:      types,
-		MinWait:            minWait,
-		MaxWait:            maxWait,
-		Interval:           interval,
-		UseInClusterConfig: inCluster,
-		Node:               node,
+		TemplateString:      tmplStr,
+		TemplatePath:        fs.Arg(0),
+		Output:              fs.Arg(1),
+		Overwrite:           overwrite,
+		Watch:               watch,
+		PreCmd:              preCmd,
+		PostCmd:             postCmd,
+		PreCmdTimeout:       preTimeout,
+		PostCmdTimeout:      postTimeout,
+		LogCmdOutput:        logCmdOutput,
+		NotifyOnChangeOnly:  changeOnly,
+		NotifySignal:        notifySignal,
+		NotifyPIDFile:       notifyPID,
+		NotifyProcess:       notifyProc,
+		NotifyURLs:          notifyURLs,
+		NotifyMethod:        notifyMethod,
+		NotifyHeaders:       notifyHdrs,
+		NotifyBody:          notifyBody,
+		NotifyRetries:       notifyRetry,
+		NotifyBackoff:       notifyDelay,
+		NotifyExec:          notifyExec,
+		NotifyExecContainer: execCtr,
+		NotifyExecSelector:  execSelector,
+		NotifyExecNamespace: execNS,
+		NotifyExecTimeout:   execTimeout,
+		ResourceTypes:       types,
+		MinWait:             minWait,
+		MaxWait:             maxWait,
+		WaitLeading:         waitLeading,
+		Interval:            interval,
+		Node:                node,
+		MetricsAddr:         metricsAddr,
+		HealthAddr:          healthAddr,
+		RenderTimeout:       renderLimit,
+		SyncTimeout:         syncTimeout,
+		Manifests:           manifests,
+		Replay:              replay,
+
+		LeaderElect:             leaderElect,
+		LeaderElectionNamespace: leaderNS,
+		LeaderElectionName:      leaderName,
+		LeaderElectionID:        leaderID,
+		LeaseDuration:           leaseTime,
+		RenewDeadline:           renewTime,
+		RetryPeriod:             retryTime,
 	}
 
+	setConnectionConfig(&conf)
+
 	gen, err := kubegen.NewGenerator(conf)
 	if err != nil {
-		log.Fatalf("error initializing generator: %v", err)
+		fatal("error initializing generator", err)
+	}
+
+	// stop watching on SIGINT, SIGQUIT or SIGTERM, and re-render on SIGHUP
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	defer stop()
+	if watch {
+		hupCh := make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+		go func() {
+			for range hupCh {
+				slog.Info("received SIGHUP. refreshing")
+				gen.(kubegen.Refresher).Refresh()
+			}
+		}()
 	}
 
-	if err := gen.Generate(); err != nil {
-		log.Fatalf("error generating output: %v", err)
+	if err := gen.GenerateContext(ctx); err != nil {
+		fatal("error generating output", err)
 	}
+	return 0
 }
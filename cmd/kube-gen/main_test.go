@@ -1,10 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	kubegen "github.com/kylemcc/kube-gen"
 	"go4.org/testing/functest"
 )
 
@@ -23,3 +32,187 @@ func TestParseWait(t *testing.T) {
 		f.In("100ms:def").Want(100*time.Millisecond, time.Duration(0), errors.New("time: invalid duration \"def\"")),
 	)
 }
+
+func TestNewLogger(t *testing.T) {
+	cases := []struct {
+		level    string
+		format   string
+		quiet    bool
+		expected string
+		err      error
+	}{
+		{"info", "json", false, `{"level":"INFO","msg":"info"}` + "\n", nil},
+		{"debug", "text", false, "level=DEBUG msg=debug\nlevel=INFO msg=info\n", nil},
+		{"warn", "text", false, "", nil},
+		{"debug", "json", true, "", nil},
+		{"verbose", "text", false, "", errors.New("invalid log level: verbose")},
+		{"info", "xml", false, "", errors.New("invalid log format: xml")},
+	}
+
+	for i, c := range cases {
+		var buf bytes.Buffer
+		logger, err := newLogger(&buf, c.level, c.format, c.quiet)
+		if !reflect.DeepEqual(err, c.err) {
+			t.Errorf("case %d failed: got error [%v] expected [%v]", i, err, c.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		// drop timestamps so the output is predictable
+		logger = slog.New(withoutTime{logger.Handler()})
+		logger.Debug("debug")
+		logger.Info("info")
+		if buf.String() != c.expected {
+			t.Errorf("case %d failed: got output %q expected %q", i, buf.String(), c.expected)
+		}
+	}
+}
+
+// withoutTime is a slog.Handler that removes the time from records
+type withoutTime struct {
+	slog.Handler
+}
+
+func (h withoutTime) Handle(ctx context.Context, r slog.Record) error {
+	r.Time = time.Time{}
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestRunTemplateTests(t *testing.T) {
+	var buf bytes.Buffer
+	if code := runTemplateTests([]string{"../../testdata/cases"}, &buf); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, buf.String())
+	}
+	if expected := "ok   upstreams\nPASS: 1 cases\n"; buf.String() != expected {
+		t.Errorf("expected [%s], got [%s]", expected, buf.String())
+	}
+
+	buf.Reset()
+	if code := runTemplateTests([]string{t.TempDir()}, &buf); code != 1 {
+		t.Errorf("expected exit code 1, got %d: %s", code, buf.String())
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	var buf bytes.Buffer
+	if code := runLint([]string{"../../testdata/cases/upstreams/template.tmpl"}, &buf); code != 0 || buf.Len() != 0 {
+		t.Errorf("expected exit code 0 and no output, got %d: %s", code, buf.String())
+	}
+
+	tmpl := filepath.Join(t.TempDir(), "t.tmpl")
+	if err := os.WriteFile(tmpl, []byte("{{ range .Service }}{{ end }}\n{{ nope }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if code := runLint([]string{tmpl}, &buf); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	expected := tmpl + ":1:10: can't evaluate field Service in type kubegen.Context\n" +
+		tmpl + ":2:4: function \"nope\" not defined\n"
+	if buf.String() != expected {
+		t.Errorf("expected [%s], got [%s]", expected, buf.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"help", "nope"}, 2, "unknown command: nope\n"},
+		{[]string{"version"}, 0, "version:  "},
+		{[]string{"funcs"}, 0, "add(int, int) int "},
+		{[]string{"lint", "../../testdata/cases/upstreams/template.tmpl"}, 0, ""},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if code := run(c.args, &buf); code != c.code {
+			t.Errorf("%v: expected exit code %d, got %d", c.args, c.code, code)
+		}
+		if !strings.HasPrefix(buf.String(), c.expected) {
+			t.Errorf("%v: expected output starting with [%s], got [%s]", c.args, c.expected, buf.String())
+		}
+	}
+}
+
+func TestRunFuncs(t *testing.T) {
+	var buf bytes.Buffer
+	if code := runFuncs(nil, &buf); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(kubegen.Funcs) {
+		t.Errorf("expected %d functions, got %d", len(kubegen.Funcs), len(lines))
+	}
+	expected := "readyPods([]v1.Pod) []v1.Pod"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected output to contain [%s], got [%s]", expected, buf.String())
+	}
+}
+
+func TestFuncSignature(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"add", "add(int, int) int"},
+		{"coalesce", "coalesce(...any) any"},
+		{"groupBy", "groupBy(any, string) map[string][]any"},
+		{"pathJoin", "pathJoin(...string) string"},
+		{"shell", "shell(string) *kubegen.ShellResult"},
+	}
+
+	for _, c := range cases {
+		if got := funcSignature(c.name, kubegen.Funcs[c.name]); got != c.expected {
+			t.Errorf("expected [%s], got [%s]", c.expected, got)
+		}
+	}
+}
+
+type snapshotFunc func(w io.Writer) error
+
+func (f snapshotFunc) Snapshot(w io.Writer) error {
+	return f(w)
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot.json.gz")
+	if err := os.WriteFile(path, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := snapshotFunc(func(w io.Writer) error {
+		io.WriteString(w, "partial") //nolint:errcheck
+		return errors.New("connection refused")
+	})
+	if err := writeSnapshot(failed, path); err == nil || err.Error() != "connection refused" {
+		t.Errorf("expected the snapshot error, got [%v]", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "previous" {
+		t.Errorf("expected a failed snapshot to leave the file alone, got [%s]", b)
+	}
+
+	ok := snapshotFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, "snapshot")
+		return err
+	})
+	if err := writeSnapshot(ok, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "snapshot" {
+		t.Errorf("expected the snapshot to be written, got [%s]", b)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected the temporary files to be removed, got %v", entries)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+func playgroundUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Printf(`Usage: kube-gen serve-playground [options] [<template>]
+
+Serve a web page for editing a template and viewing its output as it changes.
+Objects are loaded when the command starts, and may be reloaded from the page.
+Templates are rendered by kube-gen, so functions that run commands or read
+files (shell, dir and exists) are disabled unless -allow-unsafe-funcs is given.
+Only serve the playground on a trusted address
+
+Options:
+`)
+		fs.PrintDefaults()
+
+		fmt.Printf(`
+Arguments:
+  template: (Optional) path of a template file to open in the playground
+`)
+		fmt.Print(envUsage)
+	}
+}
+
+// runPlayground runs the serve-playground command, which serves the
+// playground until a signal is received
+func runPlayground(args []string, _ io.Writer) int {
+	fs := flag.NewFlagSet("serve-playground", flag.ExitOnError)
+	addConnectionFlags(fs)
+	addSelectionFlags(fs)
+	addOfflineFlags(fs)
+	addLogFlags(fs)
+	addr := fs.String("addr", "localhost:8090", "address to serve the playground on")
+	allowUnsafe := fs.Bool("allow-unsafe-funcs", false, "enable the shell, dir and exists functions, which run commands on and read files of this machine")
+	fs.Usage = playgroundUsage(fs)
+	parseFlags(fs, args)
+	setupLogging()
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var tmpl string
+	if path := fs.Arg(0); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fatal("error reading template", err)
+		}
+		tmpl = string(b)
+	}
+
+	conf := kubegen.Config{
+		TemplateString: tmpl,
+		ResourceTypes:  types,
+		Node:           node,
+		Manifests:      manifests,
+		Replay:         replay,
+	}
+	setConnectionConfig(&conf)
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fatal("error starting server", err)
+	}
+	// keep the host name given by -addr, which may be accepted in requests,
+	// with the port that was listened on
+	host, _, _ := net.SplitHostPort(*addr)
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, err := kubegen.NewPlayground(conf, kubegen.PlaygroundOptions{
+		Addr:             net.JoinHostPort(host, port),
+		AllowUnsafeFuncs: *allowUnsafe,
+	})
+	if err != nil {
+		fatal("error initializing playground", err)
+	}
+	slog.Info("serving playground", "url", "http://"+ln.Addr().String())
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{Handler: p}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background()) //nolint:errcheck
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("error serving playground", err)
+	}
+	return 0
+}
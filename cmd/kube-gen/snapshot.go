@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+func snapshotUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Printf(`Usage: kube-gen snapshot [options] <file>
+
+Write the objects a render would load to a compressed file, which can be
+rendered later using -replay
+
+Options:
+`)
+		fs.PrintDefaults()
+
+		fmt.Printf(`
+Arguments:
+  file: path to write the snapshot to, or - to write to STDOUT
+`)
+		fmt.Print(envUsage)
+	}
+}
+
+// runSnapshot runs the snapshot command, which writes the objects selected by
+// the connection, -type and -node flags to the file given as the only argument
+func runSnapshot(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	addConnectionFlags(fs)
+	addSelectionFlags(fs)
+	addOfflineFlags(fs)
+	addLogFlags(fs)
+	fs.Usage = snapshotUsage(fs)
+	parseFlags(fs, args)
+	setupLogging()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	conf := kubegen.Config{
+		ResourceTypes: types,
+		Node:          node,
+		Manifests:     manifests,
+		Replay:        replay,
+	}
+	setConnectionConfig(&conf)
+	g, err := kubegen.NewGenerator(conf)
+	if err != nil {
+		fatal("error initializing generator", err)
+	}
+	gen := g.(kubegen.Snapshotter)
+
+	path := fs.Arg(0)
+	if path == "-" {
+		if err := gen.Snapshot(w); err != nil {
+			fatal("error writing snapshot", err)
+		}
+		return 0
+	}
+
+	if err := writeSnapshot(gen, path); err != nil {
+		fatal("error writing snapshot", err)
+	}
+	slog.Info("wrote snapshot", "file", path)
+	return 0
+}
+
+// writeSnapshot writes a snapshot to a temporary file next to path, and
+// renames it to path once it's complete, so that a failure doesn't leave a
+// truncated snapshot behind
+func writeSnapshot(gen kubegen.Snapshotter, path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()           //nolint:errcheck
+			os.Remove(f.Name()) //nolint:errcheck
+		}
+	}()
+
+	if err := gen.Snapshot(f); err != nil {
+		return err
+	}
+	// CreateTemp only allows the owner to read the file
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
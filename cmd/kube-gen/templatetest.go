@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	kubegen "github.com/kylemcc/kube-gen"
+)
+
+func testUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Printf(`Usage: kube-gen test [options] <dir>
+
+Render template test cases and compare the output with the expected output.
+Each directory below <dir> containing a template.tmpl file is a test case:
+the template is rendered with the objects in the manifests directory next to
+it, and compared with the contents of expected.golden
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Print(envUsage)
+	}
+}
+
+// runTemplateTests runs the test command, returning the exit code
+func runTemplateTests(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	update := fs.Bool("update", false, "rewrite expected.golden for cases that fail, or are missing it")
+	fs.Usage = testUsage(fs)
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	results, err := kubegen.RunTemplateTests(fs.Arg(0), *update)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+
+	var failed int
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %v\n", r.Name, r.Err)
+		case !r.Passed:
+			failed++
+			fmt.Fprintf(w, "FAIL %s: output differs from expected.golden (-expected +got):\n%s\n", r.Name, r.Diff)
+		case r.Updated:
+			fmt.Fprintf(w, "ok   %s (updated)\n", r.Name)
+		default:
+			fmt.Fprintf(w, "ok   %s\n", r.Name)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(w, "FAIL: %d of %d cases failed\n", failed, len(results))
+		return 1
+	}
+	fmt.Fprintf(w, "PASS: %d cases\n", len(results))
+	return 0
+}
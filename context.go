@@ -1,6 +1,7 @@
 package kubegen
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"sync"
@@ -17,6 +18,71 @@ type Context struct {
 	Pods      []kapi.Pod
 	Services  []kapi.Service
 	Endpoints []kapi.Endpoints
+
+	// Changes lists the objects that changed since the previous render in
+	// watch mode. Empty for the initial render.
+	Changes []Change
+
+	// Clusters holds the objects of each cluster by name when objects are
+	// loaded from several clusters. Pods, Services and Endpoints then hold
+	// the objects of all clusters, annotated with the name of their cluster.
+	Clusters map[string]*Context
+}
+
+const (
+	ChangeAdd    = "add"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Change describes an object that was added, updated or deleted
+type Change struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Op        string `json:"op"`
+	// Cluster is the name of the cluster of the object when objects are
+	// loaded from several clusters
+	Cluster string `json:"cluster,omitempty"`
+}
+
+func (c Change) String() string {
+	if c.Cluster != "" {
+		return fmt.Sprintf("%s/%s/%s/%s:%s", c.Cluster, c.Kind, c.Namespace, c.Name, c.Op)
+	}
+	return fmt.Sprintf("%s/%s/%s:%s", c.Kind, c.Namespace, c.Name, c.Op)
+}
+
+// joinChanges formats changes as a comma separated list
+func joinChanges(changes []Change) string {
+	s := make([]string, len(changes))
+	for i, c := range changes {
+		s[i] = c.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// changeSet accumulates changes, keeping a single entry per object
+type changeSet struct {
+	changes []Change
+	index   map[string]int
+}
+
+func (s *changeSet) add(c Change) {
+	if s.index == nil {
+		s.index = map[string]int{}
+	}
+	key := c.Cluster + "/" + c.Kind + "/" + c.Namespace + "/" + c.Name
+	i, ok := s.index[key]
+	if !ok {
+		s.index[key] = len(s.changes)
+		s.changes = append(s.changes, c)
+		return
+	}
+	// an object that was added and then updated is still new
+	if s.changes[i].Op != ChangeAdd || c.Op != ChangeUpdate {
+		s.changes[i].Op = c.Op
+	}
 }
 
 // TODO: if running in k8s, make annotations on containing pod available
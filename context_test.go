@@ -42,3 +42,45 @@ func TestContextEnvParsedOnce(t *testing.T) {
 		t.Errorf("Context.Env should only parse the environment once. Expected [%#v] on second call. Got [%#v]\n", first, second)
 	}
 }
+
+func TestChangeSet(t *testing.T) {
+	cases := []struct {
+		input    []Change
+		expected []Change
+	}{
+		{nil, nil},
+		{
+			[]Change{{"Pod", "default", "a", ChangeAdd, ""}, {"Pod", "default", "a", ChangeUpdate, ""}},
+			[]Change{{"Pod", "default", "a", ChangeAdd, ""}},
+		},
+		{
+			[]Change{{"Pod", "default", "a", ChangeAdd, ""}, {"Pod", "default", "a", ChangeDelete, ""}},
+			[]Change{{"Pod", "default", "a", ChangeDelete, ""}},
+		},
+		{
+			[]Change{{"Pod", "default", "a", ChangeUpdate, ""}, {"Service", "default", "a", ChangeUpdate, ""}, {"Pod", "default", "a", ChangeDelete, ""}},
+			[]Change{{"Pod", "default", "a", ChangeDelete, ""}, {"Service", "default", "a", ChangeUpdate, ""}},
+		},
+		{
+			[]Change{{"Pod", "default", "a", ChangeAdd, "east"}, {"Pod", "default", "a", ChangeDelete, "west"}},
+			[]Change{{"Pod", "default", "a", ChangeAdd, "east"}, {"Pod", "default", "a", ChangeDelete, "west"}},
+		},
+	}
+
+	for i, c := range cases {
+		var s changeSet
+		for _, ch := range c.input {
+			s.add(ch)
+		}
+		if !reflect.DeepEqual(s.changes, c.expected) {
+			t.Errorf("case %d failed: got [%v] expected [%v]", i, s.changes, c.expected)
+		}
+	}
+}
+
+func TestJoinChanges(t *testing.T) {
+	changes := []Change{{"Pod", "default", "a", ChangeAdd, ""}, {"Endpoints", "kube-system", "b", ChangeDelete, "east"}}
+	if s := joinChanges(changes); s != "Pod/default/a:add,east/Endpoints/kube-system/b:delete" {
+		t.Errorf("unexpected result: %s", s)
+	}
+}
@@ -0,0 +1,202 @@
+package kubegen
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// debounceOptions configures a debouncer
+type debounceOptions struct {
+	// minWait is the quiet period: a batch is emitted once no events have
+	// been received for minWait. If zero, events are emitted immediately.
+	minWait time.Duration
+	// maxWait, if set, is the longest a burst may last. When it expires,
+	// the burst ends even if events keep arriving.
+	maxWait time.Duration
+	// leading emits the first event of a burst immediately
+	leading bool
+	// trailing emits the events received during a burst when it ends. With
+	// leading only, events after the first one of a burst are dropped.
+	// Trailing is used if neither is set.
+	trailing bool
+
+	clock clock.Clock
+	log   *slog.Logger
+}
+
+// debouncer coalesces bursts of events into batches. Batches that haven't
+// been received by the consumer are merged with the next one, so a slow
+// consumer never blocks the debouncer or causes batches to be dropped.
+type debouncer struct {
+	opts debounceOptions
+	in   <-chan any
+	out  chan []any
+
+	// the events of the current burst that haven't been emitted
+	pending  []any
+	inBurst  bool
+	minTimer clock.Timer
+	maxTimer clock.Timer
+
+	syncCh   chan chan struct{}
+	stopCh   chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newDebouncer(in <-chan any, opts debounceOptions) *debouncer {
+	if opts.clock == nil {
+		opts.clock = clock.RealClock{}
+	}
+	if opts.log == nil {
+		opts.log = slog.Default()
+	}
+	if !opts.leading {
+		opts.trailing = true
+	}
+	d := &debouncer{
+		opts:   opts,
+		in:     in,
+		out:    make(chan []any, 1),
+		syncCh: make(chan chan struct{}),
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// C returns the channel batches of events are delivered on
+func (d *debouncer) C() <-chan []any {
+	return d.out
+}
+
+// Stop stops the debouncer and waits for it to exit. Pending events are
+// discarded.
+func (d *debouncer) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+	<-d.done
+}
+
+// sync waits until the debouncer has processed all events it has received
+// and all timers that have fired
+func (d *debouncer) sync() {
+	ch := make(chan struct{})
+	select {
+	case d.syncCh <- ch:
+		<-ch
+	case <-d.done:
+	}
+}
+
+func (d *debouncer) run() {
+	defer close(d.done)
+	defer d.stopTimers()
+	for {
+		select {
+		case ev := <-d.in:
+			d.add(ev)
+		case <-timerC(d.minTimer):
+			d.opts.log.Debug("min wait time reached")
+			d.endBurst()
+		case <-timerC(d.maxTimer):
+			d.opts.log.Debug("max wait time reached")
+			d.endBurst()
+		case ch := <-d.syncCh:
+			d.pollTimers()
+			close(ch)
+		case <-d.stopCh:
+			return
+		}
+	}
+}
+
+func (d *debouncer) add(ev any) {
+	if d.opts.minWait <= 0 {
+		d.emit([]any{ev})
+		return
+	}
+
+	if !d.inBurst {
+		d.inBurst = true
+		if d.opts.maxWait > 0 {
+			d.maxTimer = d.opts.clock.NewTimer(d.opts.maxWait)
+		}
+		if d.opts.leading {
+			d.emit([]any{ev})
+		} else {
+			d.pending = append(d.pending, ev)
+		}
+	} else {
+		debounceCoalescedTotal.Inc()
+		if d.opts.trailing {
+			d.pending = append(d.pending, ev)
+		}
+	}
+
+	// restart the quiet period
+	if d.minTimer != nil {
+		d.minTimer.Stop()
+	}
+	d.minTimer = d.opts.clock.NewTimer(d.opts.minWait)
+}
+
+// endBurst emits the pending events when the quiet period or the max wait
+// time expires. The next event starts a new burst.
+func (d *debouncer) endBurst() {
+	d.stopTimers()
+	d.inBurst = false
+	if len(d.pending) > 0 {
+		d.emit(d.pending)
+		d.pending = nil
+	}
+}
+
+// emit delivers batch, merging it with a batch the consumer hasn't received
+// yet. Only the debouncer sends to out, so the send never blocks.
+func (d *debouncer) emit(batch []any) {
+	select {
+	case prev := <-d.out:
+		batch = append(prev, batch...)
+	default:
+	}
+	d.out <- batch
+}
+
+// pollTimers handles timers that have fired but haven't been received yet
+func (d *debouncer) pollTimers() {
+	for {
+		select {
+		case <-timerC(d.minTimer):
+			d.endBurst()
+		case <-timerC(d.maxTimer):
+			d.endBurst()
+		default:
+			return
+		}
+	}
+}
+
+func (d *debouncer) stopTimers() {
+	if d.minTimer != nil {
+		d.minTimer.Stop()
+		d.minTimer = nil
+	}
+	if d.maxTimer != nil {
+		d.maxTimer.Stop()
+		d.maxTimer = nil
+	}
+}
+
+// timerC returns the channel of t, or nil if t is nil
+func timerC(t clock.Timer) <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+	return t.C()
+}
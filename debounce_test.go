@@ -0,0 +1,160 @@
+package kubegen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	testclock "k8s.io/utils/clock/testing"
+)
+
+// received returns the batch waiting to be received from d, if any
+func received(d *debouncer) []any {
+	d.sync()
+	select {
+	case b := <-d.C():
+		return b
+	default:
+		return nil
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	type step struct {
+		send    []any
+		advance time.Duration
+		want    []any
+	}
+	cases := []struct {
+		name  string
+		opts  debounceOptions
+		steps []step
+	}{
+		{
+			name: "trailing",
+			opts: debounceOptions{minWait: 100 * time.Millisecond},
+			steps: []step{
+				{send: []any{"a", "b"}, advance: 50 * time.Millisecond},
+				{send: []any{"c"}, advance: 99 * time.Millisecond},
+				{advance: time.Millisecond, want: []any{"a", "b", "c"}},
+				{advance: time.Second},
+			},
+		},
+		{
+			name: "max wait",
+			opts: debounceOptions{minWait: 100 * time.Millisecond, maxWait: 250 * time.Millisecond},
+			steps: []step{
+				{send: []any{"a"}, advance: 80 * time.Millisecond},
+				{send: []any{"b"}, advance: 80 * time.Millisecond},
+				{send: []any{"c"}, advance: 80 * time.Millisecond},
+				{send: []any{"d"}, advance: 10 * time.Millisecond, want: []any{"a", "b", "c", "d"}},
+				{send: []any{"e"}, advance: 100 * time.Millisecond, want: []any{"e"}},
+			},
+		},
+		{
+			name: "leading and trailing",
+			opts: debounceOptions{minWait: 100 * time.Millisecond, leading: true, trailing: true},
+			steps: []step{
+				{send: []any{"a"}, want: []any{"a"}},
+				{send: []any{"b", "c"}, advance: 99 * time.Millisecond},
+				{advance: time.Millisecond, want: []any{"b", "c"}},
+				{send: []any{"d"}, want: []any{"d"}},
+				{advance: time.Second},
+			},
+		},
+		{
+			name: "leading only",
+			opts: debounceOptions{minWait: 100 * time.Millisecond, leading: true},
+			steps: []step{
+				{send: []any{"a"}, want: []any{"a"}},
+				{send: []any{"b"}, advance: time.Second},
+				{send: []any{"c"}, want: []any{"c"}},
+			},
+		},
+		{
+			name: "leading only with max wait",
+			opts: debounceOptions{minWait: 100 * time.Millisecond, maxWait: 150 * time.Millisecond, leading: true},
+			steps: []step{
+				{send: []any{"a"}, advance: 80 * time.Millisecond, want: []any{"a"}},
+				{send: []any{"b"}, advance: 80 * time.Millisecond},
+				{send: []any{"c"}, want: []any{"c"}},
+			},
+		},
+		{
+			name: "no wait",
+			opts: debounceOptions{},
+			steps: []step{
+				{send: []any{"a"}, want: []any{"a"}},
+				// batches that aren't received are merged
+				{send: []any{"b", "c"}, want: []any{"b", "c"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clock := testclock.NewFakeClock(time.Now())
+			in := make(chan any)
+			c.opts.clock = clock
+			d := newDebouncer(in, c.opts)
+			defer d.Stop()
+
+			for i, s := range c.steps {
+				for _, ev := range s.send {
+					in <- ev
+				}
+				d.sync()
+				clock.Step(s.advance)
+				if got := received(d); !reflect.DeepEqual(got, s.want) {
+					t.Errorf("step %d: got %v, expected %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDebouncerSlowConsumer(t *testing.T) {
+	clock := testclock.NewFakeClock(time.Now())
+	in := make(chan any)
+	d := newDebouncer(in, debounceOptions{minWait: 100 * time.Millisecond, clock: clock})
+	defer d.Stop()
+
+	// the first batch isn't received before the second is emitted
+	in <- "a"
+	d.sync()
+	clock.Step(100 * time.Millisecond)
+	in <- "b"
+	d.sync()
+	clock.Step(100 * time.Millisecond)
+
+	if got, want := received(d), []any{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, expected %v", got, want)
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	clock := testclock.NewFakeClock(time.Now())
+	in := make(chan any)
+	d := newDebouncer(in, debounceOptions{minWait: 100 * time.Millisecond, maxWait: time.Second, clock: clock})
+
+	in <- "a"
+	d.sync()
+	if !clock.HasWaiters() {
+		t.Fatal("expected the debouncer to start timers")
+	}
+
+	d.Stop()
+	d.Stop()
+	select {
+	case <-d.done:
+	default:
+		t.Fatal("expected the debouncer to exit")
+	}
+	if clock.HasWaiters() {
+		t.Error("expected the debouncer to stop its timers")
+	}
+	clock.Step(time.Second)
+	if got := received(d); got != nil {
+		t.Errorf("expected pending events to be discarded, got %v", got)
+	}
+}
@@ -31,7 +31,7 @@ package kubegen
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"os"
 	"reflect"
@@ -66,7 +66,7 @@ func dirList(path string) ([]string, error) {
 	names := []string{}
 	files, err := os.ReadDir(path)
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.Warn("template error", "error", err)
 		return names, nil
 	}
 	for _, f := range files {
@@ -338,7 +338,7 @@ func deepGetImpl(v reflect.Value, path []string) any {
 		v = v.Elem()
 	}
 	if v.Kind() == reflect.Pointer {
-		log.Printf("unable to descend into pointer of a pointer\n")
+		slog.Warn("unable to descend into pointer of a pointer")
 		return nil
 	}
 	switch v.Kind() {
@@ -349,7 +349,7 @@ func deepGetImpl(v reflect.Value, path []string) any {
 	case reflect.Slice, reflect.Array:
 		iu64, err := strconv.ParseUint(path[0], 10, 64)
 		if err != nil {
-			log.Printf("non-negative decimal number required for array/slice index, got %#v\n", path[0])
+			slog.Warn("non-negative decimal number required for array/slice index", "index", path[0])
 			return nil
 		}
 		if iu64 > math.MaxInt {
@@ -357,12 +357,12 @@ func deepGetImpl(v reflect.Value, path []string) any {
 		}
 		i := int(iu64) //nolint:gosec
 		if i >= v.Len() {
-			log.Printf("index %v out of bounds", i)
+			slog.Warn("index out of bounds", "index", i)
 			return nil
 		}
 		return deepGetImpl(v.Index(i), path[1:])
 	default:
-		log.Printf("unable to index by %s (value %v, kind %s)\n", path[0], v, v.Kind())
+		slog.Warn("unable to index value", "index", path[0], "value", v, "kind", v.Kind())
 		return nil
 	}
 }
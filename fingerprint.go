@@ -0,0 +1,190 @@
+package kubegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// Template functions that read state from outside of the Context. Templates
+// using them must always be rendered, since their output can't be predicted
+// from the Context alone.
+var volatileFuncs = map[string]bool{
+	"dir":    true,
+	"exists": true,
+	"shell":  true,
+}
+
+// templateInputs describes the fields of the Context read by a template
+type templateInputs struct {
+	fields   map[string]bool
+	all      bool
+	volatile bool
+}
+
+func (in *templateInputs) uses(field string) bool {
+	return in.all || in.fields[field]
+}
+
+// analyzeTemplate determines which fields of the Context are read by tmpl
+// and any templates it defines. The analysis is conservative: when it is
+// unclear what a template reads, all fields are considered used.
+func analyzeTemplate(tmpl *template.Template) *templateInputs {
+	in := &templateInputs{fields: map[string]bool{}}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			// templates invoked with {{ template }} may receive any value,
+			// so they are analyzed as if dot is the Context
+			in.walk(t.Tree.Root, true)
+		}
+	}
+	return in
+}
+
+// walk records the Context fields referenced by node. dotIsContext is true
+// when dot refers to the Context, rather than e.g. an element of a range.
+func (in *templateInputs) walk(node parse.Node, dotIsContext bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			in.walk(c, dotIsContext)
+		}
+	case *parse.ActionNode:
+		in.walk(n.Pipe, dotIsContext)
+	case *parse.IfNode:
+		in.walkBranch(&n.BranchNode, dotIsContext, dotIsContext)
+	case *parse.RangeNode:
+		in.walkBranch(&n.BranchNode, dotIsContext, false)
+	case *parse.WithNode:
+		in.walkBranch(&n.BranchNode, dotIsContext, false)
+	case *parse.TemplateNode:
+		in.walk(n.Pipe, dotIsContext)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			in.walk(c, dotIsContext)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			in.walk(a, dotIsContext)
+		}
+	case *parse.ChainNode:
+		in.walk(n.Node, dotIsContext)
+	case *parse.IdentifierNode:
+		in.volatile = in.volatile || volatileFuncs[n.Ident]
+	case *parse.FieldNode:
+		if dotIsContext {
+			in.fields[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		// $ always refers to the Context
+		if n.Ident[0] == "$" {
+			if len(n.Ident) > 1 {
+				in.fields[n.Ident[1]] = true
+			} else {
+				in.all = true
+			}
+		}
+	case *parse.DotNode:
+		if dotIsContext {
+			in.all = true
+		}
+	}
+}
+
+func (in *templateInputs) walkBranch(n *parse.BranchNode, dotIsContext, bodyDotIsContext bool) {
+	in.walk(n.Pipe, dotIsContext)
+	in.walk(n.List, bodyDotIsContext)
+	in.walk(n.ElseList, dotIsContext)
+}
+
+// fingerprint returns a hash of the template source and the parts of ctx it
+// reads. An empty string is returned if the template reads state from outside
+// of the Context.
+func fingerprint(tmpl *template.Template, ctx *Context) (string, error) {
+	in := analyzeTemplate(tmpl)
+	if in.volatile {
+		return "", nil
+	}
+
+	// Templates() is unordered, so sort by name for a stable hash
+	tmpls := tmpl.Templates()
+	sort.Slice(tmpls, func(i, j int) bool {
+		return tmpls[i].Name() < tmpls[j].Name()
+	})
+
+	h := sha256.New()
+	for _, t := range tmpls {
+		if t.Tree != nil {
+			io.WriteString(h, t.Name())        //nolint:errcheck
+			io.WriteString(h, t.Root.String()) //nolint:errcheck
+		}
+	}
+
+	enc := json.NewEncoder(h)
+	if err := encodeObjects(enc, ctx, in.uses); err != nil {
+		return "", err
+	}
+	if in.uses("Clusters") {
+		all := func(string) bool { return true }
+		for _, name := range ctx.clusterNames() {
+			if err := enc.Encode(name); err != nil {
+				return "", err
+			}
+			if err := encodeObjects(enc, ctx.Clusters[name], all); err != nil {
+				return "", err
+			}
+		}
+	}
+	if in.uses("Changes") {
+		if err := enc.Encode(ctx.Changes); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// encodeObjects encodes the objects of ctx whose field is used, ignoring
+// metadata that changes without affecting the objects' contents
+func encodeObjects(enc *json.Encoder, ctx *Context, uses func(string) bool) error {
+	if uses("Pods") {
+		pods := make([]any, len(ctx.Pods))
+		for i, p := range ctx.Pods {
+			p.ResourceVersion, p.ManagedFields = "", nil
+			pods[i] = p
+		}
+		if err := enc.Encode(pods); err != nil {
+			return err
+		}
+	}
+	if uses("Services") {
+		svcs := make([]any, len(ctx.Services))
+		for i, s := range ctx.Services {
+			s.ResourceVersion, s.ManagedFields = "", nil
+			svcs[i] = s
+		}
+		if err := enc.Encode(svcs); err != nil {
+			return err
+		}
+	}
+	if uses("Endpoints") {
+		eps := make([]any, len(ctx.Endpoints))
+		for i, e := range ctx.Endpoints {
+			e.ResourceVersion, e.ManagedFields = "", nil
+			eps[i] = e
+		}
+		if err := enc.Encode(eps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package kubegen
+
+import (
+	"testing"
+	"text/template"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAnalyzeTemplate(t *testing.T) {
+	cases := []struct {
+		tmpl      string
+		pods      bool
+		services  bool
+		endpoints bool
+		volatile  bool
+	}{
+		{`{{ range .Services }}{{ .Name }}{{ end }}`, false, true, false, false},
+		{`{{ range .Pods }}{{ .Status.PodIP }}{{ end }}`, true, false, false, false},
+		{`{{ range $s := .Services }}{{ range $.Endpoints }}{{ end }}{{ end }}`, false, true, true, false},
+		{`{{ with .Services }}{{ len . }}{{ else }}{{ .Pods }}{{ end }}`, true, true, false, false},
+		{`{{ range .Services }}{{ .Endpoints }}{{ end }}`, false, true, false, false},
+		{`{{ json . }}`, true, true, true, false},
+		{`{{ $ctx := $ }}{{ $ctx.Services }}`, true, true, true, false},
+		{`{{ define "svc" }}{{ .Services }}{{ end }}{{ template "svc" . }}`, true, true, true, false},
+		{`{{ define "svc" }}{{ .Name }}{{ end }}{{ range .Services }}{{ template "svc" . }}{{ end }}`, false, true, false, false},
+		{`{{ .Env.HOME }}`, false, false, false, false},
+		{`{{ range .Services }}{{ end }}{{ if exists "/tmp/foo" }}{{ end }}`, false, true, false, true},
+		{`{{ (shell "date").Stdout }}`, false, false, false, true},
+	}
+
+	for i, c := range cases {
+		tmpl, err := parseTemplateString(c.tmpl)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		in := analyzeTemplate(tmpl)
+		if in.uses("Pods") != c.pods || in.uses("Services") != c.services || in.uses("Endpoints") != c.endpoints || in.volatile != c.volatile {
+			t.Errorf("case %d failed: got [pods: %v, services: %v, endpoints: %v, volatile: %v] expected [%v, %v, %v, %v]\n", i,
+				in.uses("Pods"), in.uses("Services"), in.uses("Endpoints"), in.volatile, c.pods, c.services, c.endpoints, c.volatile)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	svcTmpl, _ := parseTemplateString(`{{ range .Services }}{{ .Name }}{{ end }}`)
+	podTmpl, _ := parseTemplateString(`{{ range .Pods }}{{ .Name }}{{ end }}`)
+	shellTmpl, _ := parseTemplateString(`{{ range .Pods }}{{ shell "true" }}{{ end }}`)
+
+	newCtx := func(phase kapi.PodPhase, podVersion string) *Context {
+		return &Context{
+			Pods: []kapi.Pod{{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod", ResourceVersion: podVersion},
+				Status:     kapi.PodStatus{Phase: phase},
+			}},
+			Services: []kapi.Service{{ObjectMeta: metav1.ObjectMeta{Name: "svc"}}},
+		}
+	}
+
+	mustFingerprint := func(tmpl *template.Template, ctx *Context) string {
+		fp, err := fingerprint(tmpl, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return fp
+	}
+
+	before := newCtx(kapi.PodPending, "1")
+	statusChanged := newCtx(kapi.PodRunning, "2")
+	versionChanged := newCtx(kapi.PodPending, "3")
+
+	svc1 := mustFingerprint(svcTmpl, before)
+	svc2 := mustFingerprint(svcTmpl, statusChanged)
+	if svc1 == "" || svc1 != svc2 {
+		t.Errorf("pod changes should not affect the fingerprint of a template that only reads services")
+	}
+
+	pod1 := mustFingerprint(podTmpl, before)
+	pod2 := mustFingerprint(podTmpl, statusChanged)
+	pod3 := mustFingerprint(podTmpl, versionChanged)
+	if pod1 == pod2 {
+		t.Errorf("pod changes should affect the fingerprint of a template that reads pods")
+	}
+	if pod1 != pod3 {
+		t.Errorf("resource version changes should not affect the fingerprint")
+	}
+	if pod1 == svc1 {
+		t.Errorf("templates with different source should have different fingerprints")
+	}
+
+	if fp := mustFingerprint(shellTmpl, before); fp != "" {
+		t.Errorf("templates using shell should not have a fingerprint, got %s", fp)
+	}
+}
@@ -3,18 +3,29 @@ package kubegen
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
-	"os/signal"
+	"strconv"
 	"sync"
-	"syscall"
+	"sync/atomic"
+	"text/template"
 	"time"
 
-	kapi "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kclient "k8s.io/client-go/kubernetes"
+	krest "k8s.io/client-go/rest"
+	kcache "k8s.io/client-go/tools/cache"
+)
+
+const (
+	// time to wait for a command's output to be closed after it exits or is killed
+	cmdWaitDelay = 5 * time.Second
+	// default time to wait for informers to sync before failing
+	defaultSyncTimeout = time.Minute
 )
 
 var validTypes = map[string]bool{
@@ -24,156 +35,473 @@ var validTypes = map[string]bool{
 }
 
 type Config struct {
-	Host               string
-	Kubeconfig         string
-	TemplatePath       string
-	TemplateString     string
-	Output             string
-	Overwrite          bool
-	Watch              bool
-	PreCmd             string
-	PostCmd            string
-	LogCmdOutput       bool
-	Interval           int
-	MinWait            time.Duration
-	MaxWait            time.Duration
-	ResourceTypes      []string
-	UseInClusterConfig bool
-	Node               string
+	Host                string
+	Kubeconfig          string
+	KubeContext         string
+	KubeCluster         string
+	KubeUser            string
+	TemplatePath        string
+	TemplateString      string
+	Output              string
+	Overwrite           bool
+	Watch               bool
+	PreCmd              string
+	PostCmd             string
+	PreCmdTimeout       time.Duration
+	PostCmdTimeout      time.Duration
+	LogCmdOutput        bool
+	NotifyOnChangeOnly  bool
+	NotifySignal        string
+	NotifyPIDFile       string
+	NotifyProcess       string
+	NotifyURLs          []string
+	NotifyMethod        string
+	NotifyHeaders       []string
+	NotifyBody          string
+	NotifyRetries       int
+	NotifyBackoff       time.Duration
+	NotifyExec          string
+	NotifyExecContainer string
+	NotifyExecSelector  string
+	NotifyExecNamespace string
+	NotifyExecTimeout   time.Duration
+	Interval            int
+	MinWait             time.Duration
+	MaxWait             time.Duration
+	WaitLeading         bool
+	ResourceTypes       []string
+	UseInClusterConfig  bool
+	Node                string
+	MetricsAddr         string
+	HealthAddr          string
+	RenderTimeout       time.Duration
+	SyncTimeout         time.Duration
+
+	// Manifests, if set, are files or directories of YAML or JSON manifests
+	// that are rendered instead of objects loaded from the API server
+	Manifests []string
+	// Replay, if set, is a file written by Snapshot that is rendered instead
+	// of objects loaded from the API server
+	Replay string
+	// Clusters, if set, are the clusters objects are loaded from. The
+	// cluster selected by the connection options is still used for leader
+	// election, ConfigMap and Secret outputs and exec notifications.
+	Clusters []ClusterConfig
+
+	// leader election. When enabled, only the replica holding the lease
+	// renders the template and runs commands and notifications.
+	LeaderElect             bool
+	LeaderElectionNamespace string
+	LeaderElectionName      string
+	LeaderElectionID        string
+	LeaseDuration           time.Duration
+	RenewDeadline           time.Duration
+	RetryPeriod             time.Duration
+
+	// credentials, TLS and rate limit settings, which override those of
+	// the kubeconfig or in-cluster config
+	BearerToken           string
+	BearerTokenFile       string
+	ClientCertificate     string
+	ClientKey             string
+	CertificateAuthority  string
+	InsecureSkipTLSVerify bool
+	Impersonate           string
+	ImpersonateGroups     []string
+	QPS                   float32
+	Burst                 int
 }
 
 type Generator interface {
+	// Generate renders the template. In watch mode, it runs until the
+	// process exits.
 	Generate() error
+	// GenerateContext renders the template. In watch mode, it re-renders the
+	// template as resources change until ctx is cancelled, then waits for any
+	// in-flight render and its commands to finish before returning.
+	// Cancelling ctx interrupts requests to the API server and notifications,
+	// but not pre and post commands.
+	GenerateContext(ctx context.Context) error
+}
+
+// Refresher is implemented by generators that can be asked to re-render the
+// template
+type Refresher interface {
+	// Refresh forces the template to be re-rendered in watch mode, even if
+	// nothing it reads has changed.
+	Refresh()
+}
+
+// Snapshotter is implemented by generators that can save the objects they
+// render
+type Snapshotter interface {
+	// Snapshot writes the objects a render would load to w, so that they
+	// can be rendered later using the Replay option.
+	Snapshot(w io.Writer) error
 }
 
 type generator struct {
 	sync.WaitGroup
 	Config Config
-	Client *kclient.Clientset
+	Client kclient.Interface
+
+	restConfig *krest.Config
 
 	loadPods bool
 	loadSvcs bool
 	loadEps  bool
+
+	notifiers       []notifier
+	lastChecksum    string
+	lastFingerprint string
+	// content of the last render in watch mode
+	lastContent []byte
+
+	// changes received since the last render in watch mode
+	changesMu sync.Mutex
+	changes   changeSet
+
+	// state reported by the health check endpoints
+	synced      []kcache.InformerSynced
+	renderStart atomic.Int64
+	rendered    atomic.Bool
+
+	// set while this replica holds the leader election lease
+	leader atomic.Bool
+
+	// clusters objects are loaded from, if configured
+	clusters []cluster
+	// in watch mode, the stores of the informers of each of the sources,
+	// which renders read instead of listing objects
+	stores []informerStores
+
+	// receives requests to re-render the template
+	refreshCh chan struct{}
 }
 
 func NewGenerator(c Config) (Generator, error) {
-	kclient, err := newKubeClient(c)
-	return &generator{
+	g := &generator{
 		Config:   c,
-		Client:   kclient,
 		loadPods: len(c.ResourceTypes) == 0 || containsString(c.ResourceTypes, "pods"),
 		loadSvcs: len(c.ResourceTypes) == 0 || containsString(c.ResourceTypes, "services"),
 		loadEps:  len(c.ResourceTypes) == 0 || containsString(c.ResourceTypes, "endpoints"),
-	}, err
+
+		refreshCh: make(chan struct{}, 1),
+	}
+
+	var err error
+	if g.offline() {
+		g.notifiers, err = g.newNotifiers()
+		return g, err
+	}
+	if g.restConfig, err = newKubeConfig(c); err != nil {
+		return g, err
+	}
+	if g.Client, err = newKubeClient(g.restConfig); err != nil {
+		return g, err
+	}
+	if err = g.newClusters(); err != nil {
+		return g, err
+	}
+	g.notifiers, err = g.newNotifiers()
+	return g, err
 }
 
 func (g *generator) Generate() error {
+	return g.GenerateContext(context.Background())
+}
+
+func (g *generator) GenerateContext(ctx context.Context) error {
 	if err := g.validateConfig(); err != nil {
 		return err
 	}
 
-	if g.Config.Watch {
-		// watch for updates
-		if err := g.watchEvents(); err != nil {
-			return err
-		}
-	} else {
+	if !g.Config.Watch {
 		// initial render
-		return g.execute()
+		return g.execute(ctx, true, nil)
 	}
 
+	// watch for updates
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := g.watchEvents(ctx)
+	if err != nil {
+		cancel()
+	}
 	g.Wait()
-	return nil
+	if err == nil {
+		g.logger().Info("stopped watching for changes")
+	}
+	return err
 }
 
-func (g *generator) execute() error {
-	ctx := &Context{}
+func (g *generator) execute(ctx context.Context, force bool, changes []Change) (err error) {
+	log := g.logger()
+	start := time.Now()
+	result := "success"
+	g.renderStart.Store(start.UnixNano())
+	defer func() {
+		g.renderStart.Store(0)
+		if err != nil {
+			result = "error"
+		} else {
+			g.rendered.Store(true)
+		}
+		rendersTotal.WithLabelValues(result).Inc()
+		renderDuration.Observe(time.Since(start).Seconds())
+		log.Debug("render finished", "result", result, "duration", time.Since(start))
+	}()
+
+	tctx, err := g.loadContext(ctx)
+	if err != nil {
+		return err
+	}
+	tctx.Changes = changes
+	if len(changes) > 0 {
+		log.Info("rendering after changes", "count", len(changes))
+		for _, c := range changes {
+			log.Debug("changed", "kind", c.Kind, "namespace", c.Namespace, "name", c.Name, "op", c.Op)
+		}
+	}
 
-	log.Println("refreshing state...")
+	var tmpl *template.Template
+	if g.Config.TemplateString != "" {
+		tmpl, err = parseTemplateString(g.Config.TemplateString)
+	} else {
+		tmpl, err = parseTemplateFile(g.Config.TemplatePath)
+	}
+	if err != nil {
+		templateErrorsTotal.Inc()
+		return err
+	}
+
+	// in watch mode, skip executing the template if nothing it reads has
+	// changed. The previous content is still compared with the output, so
+	// that an output that was modified or deleted is restored.
+	var (
+		fp      string
+		content []byte
+		reused  bool
+	)
+	if g.Config.Watch {
+		if fp, err = fingerprint(tmpl, tctx); err != nil {
+			return err
+		}
+		if !force && fp != "" && fp == g.lastFingerprint {
+			content, reused = g.lastContent, true
+		}
+		defer func() {
+			if err == nil {
+				g.lastFingerprint, g.lastContent = fp, content
+			}
+		}()
+	}
+
+	if !reused {
+		if content, err = execTemplate(tmpl, tctx); err != nil {
+			templateErrorsTotal.Inc()
+			return err
+		}
+	}
+
+	rr := newRenderResult(g.Config.Output, content)
+	rr.Changes = changes
+	if rr.Changed, err = g.outputChanged(ctx, content, rr.Checksum); err != nil {
+		return err
+	}
+	if reused && !rr.Changed {
+		log.Info("template inputs unchanged. skipping render")
+		result = "skipped"
+		return nil
+	}
+	if !rr.Changed && g.Config.NotifyOnChangeOnly {
+		log.Info("output unchanged. skipping commands and notifications", "checksum", rr.Checksum)
+		return nil
+	}
+
+	if err := g.runCmd("pre", g.Config.PreCmd, g.Config.PreCmdTimeout, rr); err != nil {
+		return err
+	}
+	if !g.isLeader() {
+		log.Warn("lost leadership while rendering. skipping output")
+		return nil
+	}
+	changed, err := g.writeOutput(ctx, content)
+	if err != nil {
+		return err
+	}
+	g.lastChecksum = rr.Checksum
+	if !changed && g.Config.NotifyOnChangeOnly {
+		// the output was modified by someone else after it was compared
+		return nil
+	}
+	if !g.isLeader() {
+		log.Warn("lost leadership while rendering. skipping commands and notifications")
+		return nil
+	}
+	if err := g.runCmd("post", g.Config.PostCmd, g.Config.PostCmdTimeout, rr); err != nil {
+		return err
+	}
+	return g.notify(ctx, rr)
+}
+
+// loadContext loads the current state of the selected resources
+func (g *generator) loadContext(ctx context.Context) (*Context, error) {
+	if g.offline() {
+		var (
+			tctx *Context
+			err  error
+		)
+		if g.Config.Replay != "" {
+			tctx, err = loadSnapshot(g.Config.Replay)
+		} else {
+			tctx, err = loadManifests(g.Config.Manifests)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tctx.groupClusters()
+		tctx.filter(g)
+		return tctx, nil
+	}
+	log := g.logger()
+	log.Debug("refreshing state")
 	start := time.Now()
+	var tctx *Context
+	if len(g.clusters) == 0 {
+		var err error
+		if tctx, err = g.loadClusterContext(ctx, 0, g.Client); err != nil {
+			return nil, err
+		}
+	} else {
+		tctx = &Context{}
+		for i, c := range g.clusters {
+			cctx, err := g.loadClusterContext(ctx, i, c.client)
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", c.name, err)
+			}
+			tctx.addCluster(c.name, cctx)
+		}
+	}
+	tctx.filter(g)
+	log.Info("refreshed state", "pods", len(tctx.Pods), "services", len(tctx.Services), "endpoints", len(tctx.Endpoints),
+		"clusters", len(tctx.Clusters), "duration", time.Since(start))
+	return tctx, nil
+}
+
+// loadClusterContext loads the selected resources of the i-th source. In
+// watch mode, they are read from the informer stores of the source, otherwise
+// they are listed using client.
+func (g *generator) loadClusterContext(ctx context.Context, i int, client kclient.Interface) (*Context, error) {
+	if g.stores != nil {
+		return g.stores[i].context(), nil
+	}
+	tctx := &Context{}
 	if g.loadPods {
 		listOptions := metav1.ListOptions{}
 		if g.Config.Node != "" {
 			listOptions.FieldSelector = fmt.Sprintf("spec.nodeName=%s", g.Config.Node)
-			log.Println("loading pods in node", g.Config.Node)
+			g.logger().Debug("loading pods in node", "node", g.Config.Node)
 		}
-		if p, err := g.Client.CoreV1().Pods(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{}); err != nil {
-			return fmt.Errorf("error loading pods: %w", err)
+		if p, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, listOptions); err != nil {
+			return nil, fmt.Errorf("error loading pods: %w", err)
 		} else {
-			ctx.Pods = p.Items
+			tctx.Pods = p.Items
 		}
 	}
 	if g.loadSvcs {
-		if p, err := g.Client.CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{}); err != nil {
-			return fmt.Errorf("error loading services: %w", err)
+		if p, err := client.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{}); err != nil {
+			return nil, fmt.Errorf("error loading services: %w", err)
 		} else {
-			ctx.Services = p.Items
+			tctx.Services = p.Items
 		}
 	}
 	if g.loadEps {
-		if p, err := g.Client.CoreV1().Endpoints(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{}); err != nil {
-			return fmt.Errorf("error loading endpoints: %w", err)
+		if p, err := client.CoreV1().Endpoints(metav1.NamespaceAll).List(ctx, metav1.ListOptions{}); err != nil {
+			return nil, fmt.Errorf("error loading endpoints: %w", err)
 		} else {
-			ctx.Endpoints = p.Items
+			tctx.Endpoints = p.Items
 		}
 	}
-	log.Printf("done. took %v\n", time.Since(start))
+	return tctx, nil
+}
 
-	var (
-		content []byte
-		err     error
-	)
-	if g.Config.TemplateString != "" {
-		content, err = execTemplateString(g.Config.TemplateString, ctx)
-	} else {
-		content, err = execTemplateFile(g.Config.TemplatePath, ctx)
-	}
-	if err != nil {
-		return err
-	}
+// recordChange adds c to the changes reported by the next render
+func (g *generator) recordChange(c Change) {
+	g.changesMu.Lock()
+	defer g.changesMu.Unlock()
+	g.changes.add(c)
+}
 
-	if err := g.runCmd(g.Config.PreCmd); err != nil {
-		return err
-	}
-	if err := g.writeFile(content); err != nil {
-		return err
+// takeChanges returns the changes received since it was last called
+func (g *generator) takeChanges() []Change {
+	g.changesMu.Lock()
+	defer g.changesMu.Unlock()
+	changes := g.changes.changes
+	g.changes = changeSet{}
+	return changes
+}
+
+// Refresh forces the template to be re-rendered in watch mode
+func (g *generator) Refresh() {
+	select {
+	case g.refreshCh <- struct{}{}:
+	default:
+		// a refresh is already pending
 	}
-	return g.runCmd(g.Config.PostCmd)
 }
 
-func (g *generator) watchEvents() error {
+// refreshEvent is sent to the render loop when a refresh is requested
+type refreshEvent struct{}
+
+// watchEvents starts watching for changes, and returns once the initial
+// render has been queued. Everything it starts stops when ctx is cancelled,
+// and is tracked by the generator's WaitGroup.
+func (g *generator) watchEvents(ctx context.Context) error {
 	if !g.Config.Watch {
 		return nil
 	}
 
 	var (
-		nWatchers int
-		podCh     chan *kapi.Pod
-		svcCh     chan *kapi.Service
-		epCh      chan *kapi.Endpoints
-		ticker    *time.Ticker
-		tickerCh  <-chan time.Time
+		ticker   *time.Ticker
+		tickerCh <-chan time.Time
 	)
 
-	// channel for signaling shutdown to watchers
-	stopCh := make(chan struct{})
-	// channel for receiving signals
-	sigCh := newSigChan()
+	// channel for receiving changes from the informers
+	changeCh := make(chan Change)
 
-	if g.loadPods {
-		nWatchers++
-		podCh = make(chan *kapi.Pod)
-		watchPods(g.Client, g.Config.Node, podCh, stopCh)
+	// renders read from the stores, which happens once they have synced:
+	// events aren't sent to the render loop before then
+	var controllers []kcache.Controller
+	for _, cl := range g.sources() {
+		var (
+			s informerStores
+			c kcache.Controller
+		)
+		if g.loadPods {
+			s.pods, c = g.watchPods(ctx, cl.client, cl.name, changeCh)
+			controllers = append(controllers, c)
+		}
+		if g.loadSvcs {
+			s.services, c = g.watchServices(ctx, cl.client, cl.name, changeCh)
+			controllers = append(controllers, c)
+		}
+		if g.loadEps {
+			s.endpoints, c = g.watchEndpoints(ctx, cl.client, cl.name, changeCh)
+			controllers = append(controllers, c)
+		}
+		g.stores = append(g.stores, s)
 	}
-	if g.loadSvcs {
-		nWatchers++
-		svcCh = make(chan *kapi.Service)
-		watchServices(g.Client, svcCh, stopCh)
+	for _, c := range controllers {
+		g.synced = append(g.synced, c.HasSynced)
+		g.Add(1)
+		go func() {
+			defer g.Done()
+			c.Run(ctx.Done())
+		}()
 	}
-	if g.loadEps {
-		nWatchers++
-		epCh = make(chan *kapi.Endpoints)
-		watchEndpoints(g.Client, epCh, stopCh)
+	if err := g.startServers(ctx); err != nil {
+		return err
 	}
 	if g.Config.Interval > 0 {
 		ticker = time.NewTicker(time.Duration(g.Config.Interval) * time.Second)
@@ -182,53 +510,132 @@ func (g *generator) watchEvents() error {
 
 	// channel for receiving events from the kubernetes api
 	eventCh := make(chan any)
+	// sends ev to the render loop, unless shutting down
+	send := func(ev any) {
+		select {
+		case eventCh <- ev:
+		case <-ctx.Done():
+		}
+	}
 	// debounce rapidly occurring events
-	debounceCh := newDebouncer(eventCh, g.Config.MinWait, g.Config.MaxWait)
+	d := newDebouncer(eventCh, debounceOptions{
+		minWait:  g.Config.MinWait,
+		maxWait:  g.Config.MaxWait,
+		leading:  g.Config.WaitLeading,
+		trailing: true,
+		log:      g.logger(),
+	})
+	g.Add(1)
 	go func() {
-		for range debounceCh {
-			if err := g.execute(); err != nil {
-				log.Printf("error rendering template: %v\n", err)
+		defer g.Done()
+		defer d.Stop()
+		for {
+			var batch []any
+			select {
+			case batch = <-d.C():
+			case <-ctx.Done():
+				return
+			}
+			if !g.isLeader() {
+				// the leader reports these changes
+				g.takeChanges()
+				g.logger().Debug("not the leader. skipping render")
+				continue
+			}
+			// a refresh or a new leader always re-renders the template
+			var force bool
+			for _, ev := range batch {
+				switch ev.(type) {
+				case refreshEvent, leaderEvent:
+					force = true
+				}
+			}
+			if err := g.execute(ctx, force, g.takeChanges()); err != nil && ctx.Err() == nil {
+				g.logger().Error("error rendering template", "error", err)
 			}
 		}
 	}()
 
-	// watch for various events that trigger template rendering
+	// watch for various events that trigger template rendering. Events
+	// received before all informers have synced are part of the initial
+	// list, and are covered by the initial render.
+	var synced atomic.Bool
 	g.Add(1)
 	go func() {
 		defer g.Done()
 		for {
+			var ev any
 			select {
-			case p := <-podCh:
-				eventCh <- p
-			case s := <-svcCh:
-				eventCh <- s
-			case e := <-epCh:
-				eventCh <- e
+			case c := <-changeCh:
+				if synced.Load() {
+					g.recordChange(c)
+				}
+				ev = c
 			case t := <-tickerCh:
-				eventCh <- t
-			case sig := <-sigCh:
-				switch sig {
-				case syscall.SIGHUP:
-					eventCh <- sig
-				case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM:
-					if ticker != nil {
-						ticker.Stop()
-					}
-					for i := 0; i < nWatchers; i++ {
-						stopCh <- struct{}{}
-					}
-					return
+				ev = t
+			case <-g.refreshCh:
+				ev = refreshEvent{}
+			case <-ctx.Done():
+				if ticker != nil {
+					ticker.Stop()
 				}
+				return
+			}
+			if synced.Load() {
+				send(ev)
 			}
 		}
 	}()
+
+	if err := g.waitForCacheSync(ctx); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	synced.Store(true)
+
+	if g.Config.LeaderElect {
+		// followers skip renders, the initial one included. The leader
+		// renders as soon as it acquires the lease.
+		if err := g.runLeaderElection(ctx, func() { send(leaderEvent{}) }); err != nil {
+			return err
+		}
+	}
+
+	// initial render
+	send(struct{}{})
 	return nil
 }
 
-func (g *generator) writeFile(content []byte) error {
+// waitForCacheSync waits for all informers to load the initial list of
+// objects, or for the sync timeout to expire
+func (g *generator) waitForCacheSync(ctx context.Context) error {
+	timeout := g.Config.SyncTimeout
+	if timeout <= 0 {
+		timeout = defaultSyncTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log := g.logger()
+	log.Info("waiting for informers to sync")
+	start := time.Now()
+	if !kcache.WaitForCacheSync(ctx.Done(), g.synced...) {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// shutting down
+			return nil
+		}
+		return fmt.Errorf("timed out waiting for informers to sync after %v", timeout)
+	}
+	log.Info("informers synced", "duration", time.Since(start))
+	return nil
+}
+
+func (g *generator) writeFile(content []byte) (bool, error) {
 	if g.Config.Output == "" {
 		os.Stdout.Write(content)
-		return nil
+		return true, nil
 	}
 
 	// write to a temp file first so we can copy it into place with a single atomic operation
@@ -237,15 +644,14 @@ func (g *generator) writeFile(content []byte) error {
 		os.Remove(tmp.Name())
 	}()
 	if err != nil {
-		return fmt.Errorf("error creating temp file: %w", err)
+		return false, fmt.Errorf("error creating temp file: %w", err)
 	}
 
 	if _, err := tmp.Write(content); err != nil {
-		return fmt.Errorf("error writing temp file: %w", err)
+		tmp.Close()
+		return false, fmt.Errorf("error writing temp file: %w", err)
 	}
 
-	tmp.Close()
-
 	var (
 		oldContent []byte
 		exists     bool
@@ -254,50 +660,155 @@ func (g *generator) writeFile(content []byte) error {
 		exists = true
 		// set permissions and ownership on new file
 		if err := setFileModeAndOwnership(tmp, fi); err != nil {
-			return err
+			tmp.Close()
+			return false, err
 		}
 		if oldContent, err = os.ReadFile(g.Config.Output); err != nil {
-			return fmt.Errorf("error comparing old version: %w", err)
+			tmp.Close()
+			return false, fmt.Errorf("error comparing old version: %w", err)
 		}
 	}
 
-	if !bytes.Equal(oldContent, content) {
-		// Always overwrite in watch mode - doesn't make sense
-		// to watch and not overwrite
-		if exists && !g.Config.Watch && !g.Config.Overwrite {
-			return fmt.Errorf("output file already exists")
-		}
+	tmp.Close()
 
-		if err = moveFile(tmp, g.Config.Output); err != nil {
-			return fmt.Errorf("error creating output file: %w", err)
-		}
-		log.Printf("output file [%s] created\n", g.Config.Output)
+	if exists && bytes.Equal(oldContent, content) {
+		return false, nil
 	}
 
-	return nil
+	// Always overwrite in watch mode - doesn't make sense
+	// to watch and not overwrite
+	if exists && !g.Config.Watch && !g.Config.Overwrite {
+		return false, fmt.Errorf("output file already exists")
+	}
+
+	if err = moveFile(tmp, g.Config.Output); err != nil {
+		return false, fmt.Errorf("error creating output file: %w", err)
+	}
+	g.logger().Info("output file created")
+	return true, nil
 }
 
-func (g *generator) runCmd(cs string) error {
+// runCmd runs a pre or post command. name identifies the command in metrics.
+func (g *generator) runCmd(name, cs string, timeout time.Duration, r *renderResult) error {
 	if cs == "" {
 		return nil
 	}
 
-	log.Printf("running command [%v]\n", cs)
-	cmd := exec.Command(shellExe, shellArg, cs)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error running command: %w", err)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	log := g.logger().With("command", name)
+	log.Info("running command", "cmd", cs)
+	cmd := exec.CommandContext(ctx, shellExe, shellArg, cs)
+	cmd.Env = append(os.Environ(),
+		"KUBEGEN_OUTPUT="+r.Output,
+		"KUBEGEN_CHECKSUM="+r.Checksum,
+		"KUBEGEN_CHANGED="+strconv.FormatBool(r.Changed),
+		"KUBEGEN_CHANGES="+joinChanges(r.Changes),
+	)
+	// run the command in its own process group so that any children
+	// are killed along with it when the timeout expires
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
 	}
+	cmd.WaitDelay = cmdWaitDelay
+
 	if g.Config.LogCmdOutput {
-		log.Printf("%s: %s\n", cs, out)
+		out := &lineLogger{log: log}
+		defer out.Flush()
+		cmd.Stdout = out
+		cmd.Stderr = out
+	}
+
+	err := cmd.Run()
+	observeCommand(name, err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("error running command: timed out after %v", timeout)
+		}
+		return fmt.Errorf("error running command: %w", err)
 	}
 	return nil
 }
 
+// lineLogger is an io.Writer that logs each line written to it
+type lineLogger struct {
+	log *slog.Logger
+	buf []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.log.Info("command output", "line", string(l.buf[:i]))
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs any remaining partial line
+func (l *lineLogger) Flush() {
+	if len(l.buf) > 0 {
+		l.log.Info("command output", "line", string(l.buf))
+		l.buf = nil
+	}
+}
+
+// logger returns a logger annotated with the template and output
+func (g *generator) logger() *slog.Logger {
+	tmpl := g.Config.TemplatePath
+	if g.Config.TemplateString != "" {
+		tmpl = "stdin"
+	}
+	return slog.With("template", tmpl, "output", g.Config.Output)
+}
+
+// offline reports whether objects are loaded from manifests or a snapshot
+// rather than the API server
+func (g *generator) offline() bool {
+	return len(g.Config.Manifests) > 0 || g.Config.Replay != ""
+}
+
 func (g *generator) validateConfig() error {
 	if err := validateTypes(g.Config.ResourceTypes); err != nil {
 		return err
 	}
+	if _, err := parseObjectOutput(g.Config.Output); err != nil {
+		return err
+	}
+	if g.Config.LeaderElect && !g.Config.Watch {
+		return errors.New("leader election requires watch mode")
+	}
+	if g.offline() {
+		src := "manifests"
+		if g.Config.Replay != "" {
+			if len(g.Config.Manifests) > 0 {
+				return errors.New("manifests and a snapshot cannot be rendered together")
+			}
+			src = "snapshots"
+		}
+		if g.Config.Watch {
+			return fmt.Errorf("%s cannot be watched", src)
+		}
+		if len(g.Config.Clusters) > 0 {
+			return fmt.Errorf("%s cannot be combined with clusters", src)
+		}
+		if o, _ := parseObjectOutput(g.Config.Output); o != nil {
+			return fmt.Errorf("%s output requires an API server", o.Kind)
+		}
+		if g.Config.NotifyExec != "" {
+			return errors.New("exec notifications require an API server")
+		}
+	}
 	return nil
 }
 
@@ -309,46 +820,3 @@ func validateTypes(types []string) error {
 	}
 	return nil
 }
-
-func newSigChan() <-chan os.Signal {
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
-	return ch
-}
-
-func newDebouncer(inCh chan any, minWait, maxWait time.Duration) chan any {
-	if minWait < 1 {
-		return inCh
-	}
-
-	outCh := make(chan any, 100)
-	go func() {
-		var (
-			latestEvent any
-			minTimer    <-chan time.Time
-			maxTimer    <-chan time.Time
-		)
-		for {
-			select {
-			case obj := <-inCh:
-				latestEvent = obj
-				minTimer = time.After(minWait)
-				if maxTimer == nil && maxWait > 0 {
-					maxTimer = time.After(maxWait)
-				}
-			case <-minTimer:
-				log.Printf("min wait time reached")
-				minTimer = nil
-				maxTimer = nil
-				outCh <- latestEvent
-			case <-maxTimer:
-				log.Printf("max wait time reached")
-				minTimer = nil
-				maxTimer = nil
-				outCh <- latestEvent
-			}
-		}
-	}()
-
-	return outCh
-}
@@ -1,9 +1,20 @@
 package kubegen
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
 )
 
 func TestValidateConfig(t *testing.T) {
@@ -22,3 +33,228 @@ func TestValidateConfig(t *testing.T) {
 		}
 	}
 }
+
+// countingNotifier counts the number of times it is notified
+type countingNotifier struct {
+	count atomic.Int32
+}
+
+func (n *countingNotifier) notify(context.Context, *renderResult) error {
+	n.count.Add(1)
+	return nil
+}
+
+// waitFor polls cond until it returns true or the timeout expires
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met after %v", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// watchContext returns a context that is cancelled when the test finishes,
+// after which the test waits for g to shut down
+func watchContext(t *testing.T, g *generator) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		g.Wait()
+	})
+	return ctx
+}
+
+func TestWatchEventsInitialRender(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newPod("default", "pod-1", nil, kapi.PodRunning),
+		newPod("default", "pod-2", nil, kapi.PodRunning),
+		&kapi.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"}},
+		&kapi.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"}},
+	)
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	g := &generator{
+		Config: Config{
+			Watch:          true,
+			TemplateString: `{{ len .Pods }} {{ len .Services }} {{ len .Endpoints }}`,
+			Output:         out,
+		},
+		Client:    client,
+		loadPods:  true,
+		loadSvcs:  true,
+		loadEps:   true,
+		notifiers: []notifier{n},
+	}
+
+	if err := g.watchEvents(watchContext(t, g)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() > 0 })
+
+	// the initial list must not trigger additional renders
+	time.Sleep(100 * time.Millisecond)
+	if c := n.count.Load(); c != 1 {
+		t.Errorf("expected exactly 1 initial render, got %d", c)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "2 1 1" {
+		t.Errorf("expected output [2 1 1], got [%s]", b)
+	}
+}
+
+func TestWatchEventsSyncTimeout(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "services", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unavailable")
+	})
+	g := &generator{
+		Config:   Config{Watch: true, SyncTimeout: 100 * time.Millisecond},
+		Client:   client,
+		loadSvcs: true,
+	}
+
+	if err := g.watchEvents(watchContext(t, g)); err == nil || err.Error() != "timed out waiting for informers to sync after 100ms" {
+		t.Errorf("expected sync timeout error, got %v", err)
+	}
+}
+
+func TestWatchEventsChanges(t *testing.T) {
+	client := fake.NewSimpleClientset(newPod("default", "pod-1", nil, kapi.PodRunning))
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	g := &generator{
+		Config: Config{
+			Watch:          true,
+			TemplateString: `{{ range .Changes }}{{ . }} {{ end }}`,
+			Output:         out,
+			Overwrite:      true,
+		},
+		Client:    client,
+		loadPods:  true,
+		notifiers: []notifier{n},
+	}
+
+	if err := g.watchEvents(watchContext(t, g)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() > 0 })
+
+	pod := newPod("default", "pod-2", nil, kapi.PodRunning)
+	if _, err := client.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() > 1 })
+	if b, _ := os.ReadFile(out); string(b) != "Pod/default/pod-2:add " {
+		t.Errorf("expected output [Pod/default/pod-2:add ], got [%s]", b)
+	}
+}
+
+func TestWatchEventsRendersFromCache(t *testing.T) {
+	client := fake.NewSimpleClientset(newPod("default", "pod-b", nil, kapi.PodRunning))
+	var lists atomic.Int32
+	client.PrependReactor("list", "pods", func(ktesting.Action) (bool, runtime.Object, error) {
+		lists.Add(1)
+		return false, nil, nil
+	})
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	g := &generator{
+		Config: Config{
+			Watch:          true,
+			TemplateString: `{{ range .Pods }}{{ .Name }} {{ end }}`,
+			Output:         out,
+		},
+		Client:    client,
+		loadPods:  true,
+		notifiers: []notifier{n},
+	}
+
+	if err := g.watchEvents(watchContext(t, g)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() == 1 })
+	pod := newPod("default", "pod-a", nil, kapi.PodRunning)
+	if _, err := client.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() == 2 })
+
+	// objects are sorted like the results of a List
+	if b, _ := os.ReadFile(out); string(b) != "pod-a pod-b " {
+		t.Errorf("expected output [pod-a pod-b ], got [%s]", b)
+	}
+	// only the informer lists pods
+	if l := lists.Load(); l != 1 {
+		t.Errorf("expected 1 list request, got %d", l)
+	}
+}
+
+func TestWatchEventsRestoresOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	g := &generator{
+		Config: Config{
+			Watch:              true,
+			TemplateString:     `{{ len .Services }}`,
+			Output:             out,
+			Interval:           1,
+			NotifyOnChangeOnly: true,
+		},
+		Client:    fake.NewSimpleClientset(),
+		loadSvcs:  true,
+		notifiers: []notifier{n},
+	}
+
+	if err := g.watchEvents(watchContext(t, g)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() == 1 })
+
+	// the inputs of the template are unchanged, but the output no longer
+	// holds the rendered content, so the next tick writes it again
+	if err := os.Remove(out); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() == 2 })
+	if b, _ := os.ReadFile(out); string(b) != "0" {
+		t.Errorf("expected output [0], got [%s]", b)
+	}
+}
+
+func TestGenerateContextRefresh(t *testing.T) {
+	n := &countingNotifier{}
+	g := &generator{
+		Config: Config{
+			Watch:          true,
+			TemplateString: `{{ len .Services }}`,
+			Output:         filepath.Join(t.TempDir(), "out"),
+		},
+		Client:    fake.NewSimpleClientset(),
+		loadSvcs:  true,
+		notifiers: []notifier{n},
+		refreshCh: make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- g.GenerateContext(ctx)
+	}()
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() == 1 })
+
+	// nothing changed, but a refresh always renders
+	g.Refresh()
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() == 2 })
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GenerateContext did not return after the context was cancelled")
+	}
+}
@@ -0,0 +1,135 @@
+//go:build !windows
+
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+	})
+	return &buf
+}
+
+// loggedValues returns the value of attr for each log record with the given message
+func loggedValues(t *testing.T, buf *bytes.Buffer, msg, attr string) []string {
+	t.Helper()
+	var values []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if rec["msg"] == msg {
+			values = append(values, fmt.Sprint(rec[attr]))
+		}
+	}
+	return values
+}
+
+func TestRunCmdEnvAndOutput(t *testing.T) {
+	buf := captureLog(t)
+	g := &generator{Config: Config{LogCmdOutput: true}}
+	r := &renderResult{Output: "/tmp/out", Checksum: "abc", Changed: true}
+
+	cs := `echo "$KUBEGEN_OUTPUT $KUBEGEN_CHECKSUM $KUBEGEN_CHANGED"; echo second >&2; printf partial`
+	if err := g.runCmd("test", cs, 0, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cmds := loggedValues(t, buf, "running command", "cmd"); !reflect.DeepEqual(cmds, []string{cs}) {
+		t.Errorf("unexpected commands logged: %v", cmds)
+	}
+	expected := []string{"/tmp/out abc true", "second", "partial"}
+	if lines := loggedValues(t, buf, "command output", "line"); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("unexpected command output logged. Expected %q got %q", expected, lines)
+	}
+}
+
+func TestRunCmdNoLogOutput(t *testing.T) {
+	buf := captureLog(t)
+	g := &generator{}
+	if err := g.runCmd("test", "echo hello", 0, &renderResult{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := loggedValues(t, buf, "command output", "line"); len(lines) > 0 {
+		t.Errorf("command output should not be logged: %q", lines)
+	}
+}
+
+func TestRunCmdTimeout(t *testing.T) {
+	captureLog(t)
+	g := &generator{Config: Config{LogCmdOutput: true}}
+
+	// the background sleep holds the output pipe open, so this only returns
+	// promptly if the whole process group is killed
+	start := time.Now()
+	err := g.runCmd("test", "sleep 30 & sleep 30", 100*time.Millisecond, &renderResult{})
+	if err == nil || err.Error() != "error running command: timed out after 100ms" {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > cmdWaitDelay/2 {
+		t.Errorf("command took %v to be killed", elapsed)
+	}
+}
+
+func TestRunCmdFailure(t *testing.T) {
+	captureLog(t)
+	g := &generator{}
+	if err := g.runCmd("test", "exit 3", time.Second, &renderResult{}); err == nil || err.Error() != "error running command: exit status 3" {
+		t.Errorf("expected exit status error, got %v", err)
+	}
+}
+
+func TestGenerateContextWaitsForCommands(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	g := &generator{
+		Config: Config{
+			Watch:          true,
+			TemplateString: "content",
+			Output:         filepath.Join(dir, "out"),
+			PostCmd:        "sleep 0.5 && touch " + marker,
+		},
+		Client:   fake.NewSimpleClientset(),
+		loadSvcs: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- g.GenerateContext(ctx)
+	}()
+	waitFor(t, 5*time.Second, func() bool { return g.renderStart.Load() != 0 })
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GenerateContext did not return after the context was cancelled")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("expected the post command to finish before returning: %v", err)
+	}
+}
@@ -1,130 +1,261 @@
 package kubegen
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+
 	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kselector "k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	kclient "k8s.io/client-go/kubernetes"
 	krest "k8s.io/client-go/rest"
 	kcache "k8s.io/client-go/tools/cache"
 	kcmd "k8s.io/client-go/tools/clientcmd"
+	kcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-// Initializes a new Kubernetes API Client
-func newKubeClient(c Config) (*kclient.Clientset, error) {
-	var config *krest.Config
-	var err error
-	if c.Host == "" && !c.UseInClusterConfig {
-		// use the current context in kubeconfig
-		config, err = kcmd.BuildConfigFromFlags("", c.Kubeconfig)
-		if err != nil {
-			return nil, err
-		}
-	} else if c.UseInClusterConfig {
-		config, err = krest.InClusterConfig()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		config = &krest.Config{
+// Builds the configuration used to connect to the Kubernetes API
+func newKubeConfig(c Config) (*krest.Config, error) {
+	var (
+		rc  *krest.Config
+		err error
+	)
+	switch {
+	case c.Host == "" && !c.UseInClusterConfig:
+		rc, err = kubeconfigClientConfig(c).ClientConfig()
+	case c.KubeContext != "" || c.KubeCluster != "" || c.KubeUser != "":
+		return nil, errors.New("a kubeconfig context, cluster or user cannot be used with a host or in-cluster config")
+	case c.UseInClusterConfig:
+		rc, err = krest.InClusterConfig()
+	default:
+		rc = &krest.Config{
 			Host:          c.Host,
 			ContentConfig: krest.ContentConfig{GroupVersion: &kapi.SchemeGroupVersion},
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyConnectionOptions(rc, c); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// applyConnectionOptions overrides the credentials, TLS and rate limit
+// settings of rc with those set in c
+func applyConnectionOptions(rc *krest.Config, c Config) error {
+	switch {
+	case c.BearerToken != "" && c.BearerTokenFile != "":
+		return errors.New("a bearer token and a bearer token file cannot be used together")
+	case (c.ClientCertificate == "") != (c.ClientKey == ""):
+		return errors.New("a client certificate requires a client key, and a client key requires a client certificate")
+	case c.InsecureSkipTLSVerify && c.CertificateAuthority != "":
+		return errors.New("a certificate authority cannot be used when skipping TLS verification")
+	case len(c.ImpersonateGroups) > 0 && c.Impersonate == "":
+		return errors.New("impersonating groups requires a user to impersonate")
+	}
+
+	if c.BearerToken != "" || c.BearerTokenFile != "" {
+		// the token replaces any other way of authenticating as a user. A
+		// token file takes precedence over a token in rc.
+		rc.BearerToken, rc.BearerTokenFile = c.BearerToken, c.BearerTokenFile
+		rc.Username, rc.Password = "", ""
+		rc.AuthProvider, rc.ExecProvider = nil, nil
+	}
+	if c.ClientCertificate != "" {
+		rc.CertFile, rc.CertData = c.ClientCertificate, nil
+		rc.KeyFile, rc.KeyData = c.ClientKey, nil
+	}
+	if c.CertificateAuthority != "" {
+		rc.CAFile, rc.CAData = c.CertificateAuthority, nil
+	}
+	if c.InsecureSkipTLSVerify {
+		rc.Insecure = true
+		rc.CAFile, rc.CAData = "", nil
+	}
+	if c.Impersonate != "" {
+		rc.Impersonate = krest.ImpersonationConfig{UserName: c.Impersonate, Groups: c.ImpersonateGroups}
+	}
+	if c.QPS > 0 {
+		rc.QPS = c.QPS
+	}
+	if c.Burst > 0 {
+		rc.Burst = c.Burst
+	}
+	return nil
+}
+
+// kubeconfigClientConfig loads the kubeconfig files given by c.Kubeconfig,
+// which may be a list of paths separated like $KUBECONFIG, or the default
+// files if it is empty. Multiple files are merged, and the selected context
+// (the current context by default) may be overridden by c.
+func kubeconfigClientConfig(c Config) kcmd.ClientConfig {
+	rules := kcmd.NewDefaultClientConfigLoadingRules()
+	if paths := filepath.SplitList(c.Kubeconfig); len(paths) == 1 {
+		// a single file must exist
+		rules.ExplicitPath = paths[0]
+	} else if len(paths) > 1 {
+		rules.Precedence = paths
+	}
+	overrides := &kcmd.ConfigOverrides{
+		CurrentContext: c.KubeContext,
+		Context: kcmdapi.Context{
+			Cluster:  c.KubeCluster,
+			AuthInfo: c.KubeUser,
+		},
+	}
+	return kcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+// Initializes a new Kubernetes API Client
+func newKubeClient(config *krest.Config) (*kclient.Clientset, error) {
 	return kclient.NewForConfig(config)
 }
 
-func podsListWatch(client *kclient.Clientset, node string) *kcache.ListWatch {
+// The list watchers use the typed clients rather than the REST client
+// so that they can be used with a fake clientset
+
+func podsListWatch(ctx context.Context, client kclient.Interface, node string) *kcache.ListWatch {
 	var selector kselector.Selector
 	if selector = kselector.Everything(); node != "" {
 		selector = kselector.OneTermEqualSelector("spec.nodeName", node)
 	}
-	return kcache.NewListWatchFromClient(client.CoreV1().RESTClient(), "pods", kapi.NamespaceAll, selector)
-}
-
-func svcListWatch(client *kclient.Clientset) *kcache.ListWatch {
-	return kcache.NewListWatchFromClient(client.CoreV1().RESTClient(), "services", kapi.NamespaceAll, kselector.Everything())
-}
-
-func epListWatch(client *kclient.Clientset) *kcache.ListWatch {
-	return kcache.NewListWatchFromClient(client.CoreV1().RESTClient(), "endpoints", kapi.NamespaceAll, kselector.Everything())
-}
-
-func watchPods(client *kclient.Clientset, node string, ch chan<- *kapi.Pod, stopCh chan struct{}) kcache.Store {
-	store, controller := kcache.NewInformer(
-		podsListWatch(client, node),
-		&kapi.Pod{},
-		0,
-		kcache.ResourceEventHandlerFuncs{
-			AddFunc: func(v any) {
-				if p, ok := v.(*kapi.Pod); ok {
-					ch <- p
-				}
-			},
-			UpdateFunc: func(ov, nv any) {
-				if p, ok := nv.(*kapi.Pod); ok {
-					ch <- p
-				}
-			},
-			DeleteFunc: func(v any) {
-				if p, ok := v.(*kapi.Pod); ok {
-					ch <- p
-				}
-			},
-		})
-	go controller.Run(stopCh)
-	return store
-}
-
-func watchServices(client *kclient.Clientset, ch chan<- *kapi.Service, stopCh chan struct{}) kcache.Store {
-	store, controller := kcache.NewInformer(
-		svcListWatch(client),
-		&kapi.Service{},
-		0,
-		kcache.ResourceEventHandlerFuncs{
-			AddFunc: func(v any) {
-				if s, ok := v.(*kapi.Service); ok {
-					ch <- s
-				}
-			},
-			UpdateFunc: func(ov, nv any) {
-				if s, ok := nv.(*kapi.Service); ok {
-					ch <- s
-				}
-			},
-			DeleteFunc: func(v any) {
-				if s, ok := v.(*kapi.Service); ok {
-					ch <- s
-				}
-			},
-		})
-	go controller.Run(stopCh)
-	return store
-}
-
-func watchEndpoints(client *kclient.Clientset, ch chan<- *kapi.Endpoints, stopCh chan struct{}) kcache.Store {
-	store, controller := kcache.NewInformer(
-		epListWatch(client),
-		&kapi.Endpoints{},
-		0,
-		kcache.ResourceEventHandlerFuncs{
-			AddFunc: func(v any) {
-				if e, ok := v.(*kapi.Endpoints); ok {
-					ch <- e
-				}
-			},
-			UpdateFunc: func(ov, nv any) {
-				if e, ok := nv.(*kapi.Endpoints); ok {
-					ch <- e
-				}
-			},
-			DeleteFunc: func(v any) {
-				if e, ok := v.(*kapi.Endpoints); ok {
-					ch <- e
-				}
-			},
-		})
-	go controller.Run(stopCh)
-	return store
+	return &kcache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector.String()
+			return client.CoreV1().Pods(kapi.NamespaceAll).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector.String()
+			return client.CoreV1().Pods(kapi.NamespaceAll).Watch(ctx, opts)
+		},
+	}
+}
+
+func svcListWatch(ctx context.Context, client kclient.Interface) *kcache.ListWatch {
+	return &kcache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Services(kapi.NamespaceAll).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Services(kapi.NamespaceAll).Watch(ctx, opts)
+		},
+	}
+}
+
+func epListWatch(ctx context.Context, client kclient.Interface) *kcache.ListWatch {
+	return &kcache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Endpoints(kapi.NamespaceAll).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Endpoints(kapi.NamespaceAll).Watch(ctx, opts)
+		},
+	}
+}
+
+// The informers stop, and their list and watch requests are cancelled, when
+// ctx is cancelled. They are started by the caller.
+
+func (g *generator) watchPods(ctx context.Context, client kclient.Interface, cluster string, ch chan<- Change) (kcache.Store, kcache.Controller) {
+	return kcache.NewInformer(podsListWatch(ctx, client, g.Config.Node), &kapi.Pod{}, 0,
+		g.changeHandler("pods", "Pod", cluster, ch, ctx.Done()))
+}
+
+func (g *generator) watchServices(ctx context.Context, client kclient.Interface, cluster string, ch chan<- Change) (kcache.Store, kcache.Controller) {
+	return kcache.NewInformer(svcListWatch(ctx, client), &kapi.Service{}, 0,
+		g.changeHandler("services", "Service", cluster, ch, ctx.Done()))
+}
+
+func (g *generator) watchEndpoints(ctx context.Context, client kclient.Interface, cluster string, ch chan<- Change) (kcache.Store, kcache.Controller) {
+	return kcache.NewInformer(epListWatch(ctx, client), &kapi.Endpoints{}, 0,
+		g.changeHandler("endpoints", "Endpoints", cluster, ch, ctx.Done()))
+}
+
+// informerStores holds the objects watched by the informers of a cluster.
+// Stores of types that aren't loaded are nil.
+type informerStores struct {
+	pods      kcache.Store
+	services  kcache.Store
+	endpoints kcache.Store
+}
+
+// context returns the objects in the stores
+func (s *informerStores) context() *Context {
+	ctx := &Context{}
+	if s.pods != nil {
+		for _, o := range storeObjects(s.pods) {
+			ctx.Pods = append(ctx.Pods, *o.(*kapi.Pod))
+		}
+	}
+	if s.services != nil {
+		for _, o := range storeObjects(s.services) {
+			ctx.Services = append(ctx.Services, *o.(*kapi.Service))
+		}
+	}
+	if s.endpoints != nil {
+		for _, o := range storeObjects(s.endpoints) {
+			ctx.Endpoints = append(ctx.Endpoints, *o.(*kapi.Endpoints))
+		}
+	}
+	return ctx
+}
+
+// storeObjects returns copies of the objects in s, which are shared with its
+// informer, sorted by namespace and name like the results of a List
+func storeObjects(s kcache.Store) []runtime.Object {
+	items := s.List()
+	objs := make([]runtime.Object, 0, len(items))
+	for _, o := range items {
+		objs = append(objs, o.(runtime.Object).DeepCopyObject())
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		a, b := objs[i].(metav1.Object), objs[j].(metav1.Object)
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		return a.GetName() < b.GetName()
+	})
+	return objs
+}
+
+// changeHandler returns an event handler that sends a Change describing
+// each event received by an informer of the named cluster to ch, until stopCh
+// is closed
+func (g *generator) changeHandler(resource, kind, cluster string, ch chan<- Change, stopCh <-chan struct{}) kcache.ResourceEventHandlerFuncs {
+	log := g.logger()
+	send := func(op string, obj any) {
+		if d, ok := obj.(kcache.DeletedFinalStateUnknown); ok {
+			obj = d.Obj
+		}
+		m, ok := obj.(metav1.Object)
+		if !ok {
+			return
+		}
+		informerEventsTotal.WithLabelValues(resource, op).Inc()
+		log.Debug("received event", "resource", resource, "event", op, "namespace", m.GetNamespace(), "name", m.GetName(),
+			"cluster", cluster)
+		select {
+		case ch <- Change{Kind: kind, Namespace: m.GetNamespace(), Name: m.GetName(), Op: op, Cluster: cluster}:
+		case <-stopCh:
+		}
+	}
+	return kcache.ResourceEventHandlerFuncs{
+		AddFunc: func(v any) {
+			send(ChangeAdd, v)
+		},
+		UpdateFunc: func(ov, nv any) {
+			send(ChangeUpdate, nv)
+		},
+		DeleteFunc: func(v any) {
+			send(ChangeDelete, v)
+		},
+	}
 }
 
 // IsPodReady returns true if a pod is ready; false otherwise.
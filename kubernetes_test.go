@@ -0,0 +1,229 @@
+package kubegen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	krest "k8s.io/client-go/rest"
+)
+
+const testKubeconfigClusters = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com
+- name: west
+  cluster:
+    server: https://west.example.com
+current-context: east
+contexts:
+- name: east
+  context:
+    cluster: east
+    user: admin
+- name: west
+  context:
+    cluster: west
+    user: viewer
+`
+
+const testKubeconfigUsers = `apiVersion: v1
+kind: Config
+users:
+- name: admin
+  user:
+    token: admin-token
+- name: viewer
+  user:
+    token: viewer-token
+`
+
+func TestNewKubeConfig(t *testing.T) {
+	dir := t.TempDir()
+	clusters := filepath.Join(dir, "clusters")
+	users := filepath.Join(dir, "users")
+	if err := os.WriteFile(clusters, []byte(testKubeconfigClusters), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(users, []byte(testKubeconfigUsers), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	merged := clusters + string(filepath.ListSeparator) + users
+
+	cases := []struct {
+		name   string
+		config Config
+		host   string
+		token  string
+		err    error
+	}{
+		{
+			name:   "current context",
+			config: Config{Kubeconfig: merged},
+			host:   "https://east.example.com",
+			token:  "admin-token",
+		},
+		{
+			name:   "context",
+			config: Config{Kubeconfig: merged, KubeContext: "west"},
+			host:   "https://west.example.com",
+			token:  "viewer-token",
+		},
+		{
+			name:   "cluster and user",
+			config: Config{Kubeconfig: merged, KubeCluster: "west", KubeUser: "admin"},
+			host:   "https://west.example.com",
+			token:  "admin-token",
+		},
+		{
+			name:   "missing files in a list are skipped",
+			config: Config{Kubeconfig: merged + string(filepath.ListSeparator) + filepath.Join(dir, "missing")},
+			host:   "https://east.example.com",
+			token:  "admin-token",
+		},
+		{
+			name:   "unknown context",
+			config: Config{Kubeconfig: merged, KubeContext: "north"},
+			err:    errors.New(`context "north" does not exist`),
+		},
+		{
+			name:   "context with host",
+			config: Config{Host: "http://localhost:8001", KubeContext: "west"},
+			err:    errors.New("a kubeconfig context, cluster or user cannot be used with a host or in-cluster config"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rc, err := newKubeConfig(c.config)
+			if c.err != nil {
+				if err == nil || !strings.Contains(err.Error(), c.err.Error()) {
+					t.Fatalf("expected error [%v], got [%v]", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rc.Host != c.host || rc.BearerToken != c.token {
+				t.Errorf("expected host %s and token %s, got %s and %s", c.host, c.token, rc.Host, rc.BearerToken)
+			}
+		})
+	}
+}
+
+func TestApplyConnectionOptions(t *testing.T) {
+	inCluster := func() *krest.Config {
+		return &krest.Config{
+			Host:            "https://10.0.0.1:443",
+			BearerToken:     "sa-token",
+			BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			TLSClientConfig: krest.TLSClientConfig{CAFile: "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"},
+		}
+	}
+	cases := []struct {
+		name     string
+		base     *krest.Config
+		config   Config
+		expected *krest.Config
+		err      error
+	}{
+		{
+			name:     "no options",
+			base:     inCluster(),
+			expected: inCluster(),
+		},
+		{
+			name:   "token replaces the token file",
+			base:   inCluster(),
+			config: Config{BearerToken: "token"},
+			expected: &krest.Config{
+				Host:            "https://10.0.0.1:443",
+				BearerToken:     "token",
+				TLSClientConfig: krest.TLSClientConfig{CAFile: "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"},
+			},
+		},
+		{
+			name: "host",
+			base: &krest.Config{Host: "https://api.example.com"},
+			config: Config{
+				BearerTokenFile:      "/etc/kube-gen/token",
+				ClientCertificate:    "tls.crt",
+				ClientKey:            "tls.key",
+				CertificateAuthority: "ca.crt",
+				Impersonate:          "kube-gen",
+				ImpersonateGroups:    []string{"viewers"},
+				QPS:                  20,
+				Burst:                40,
+			},
+			expected: &krest.Config{
+				Host:            "https://api.example.com",
+				BearerTokenFile: "/etc/kube-gen/token",
+				TLSClientConfig: krest.TLSClientConfig{CertFile: "tls.crt", KeyFile: "tls.key", CAFile: "ca.crt"},
+				Impersonate:     krest.ImpersonationConfig{UserName: "kube-gen", Groups: []string{"viewers"}},
+				QPS:             20,
+				Burst:           40,
+			},
+		},
+		{
+			name:   "insecure",
+			base:   &krest.Config{Host: "https://api.example.com", TLSClientConfig: krest.TLSClientConfig{CAData: []byte("ca")}},
+			config: Config{InsecureSkipTLSVerify: true},
+			expected: &krest.Config{
+				Host:            "https://api.example.com",
+				TLSClientConfig: krest.TLSClientConfig{Insecure: true},
+			},
+		},
+		{
+			name:   "token and token file",
+			config: Config{BearerToken: "token", BearerTokenFile: "token"},
+			err:    errors.New("a bearer token and a bearer token file cannot be used together"),
+		},
+		{
+			name:   "certificate without key",
+			config: Config{ClientCertificate: "tls.crt"},
+			err:    errors.New("a client certificate requires a client key, and a client key requires a client certificate"),
+		},
+		{
+			name:   "insecure with certificate authority",
+			config: Config{InsecureSkipTLSVerify: true, CertificateAuthority: "ca.crt"},
+			err:    errors.New("a certificate authority cannot be used when skipping TLS verification"),
+		},
+		{
+			name:   "groups without user",
+			config: Config{ImpersonateGroups: []string{"viewers"}},
+			err:    errors.New("impersonating groups requires a user to impersonate"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rc := c.base
+			if rc == nil {
+				rc = &krest.Config{}
+			}
+			err := applyConnectionOptions(rc, c.config)
+			if !reflect.DeepEqual(err, c.err) {
+				t.Fatalf("expected error [%v], got [%v]", c.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(rc, c.expected) {
+				t.Errorf("expected %+v, got %+v", c.expected, rc)
+			}
+		})
+	}
+}
+
+func TestNewKubeConfigHost(t *testing.T) {
+	rc, err := newKubeConfig(Config{Host: "https://api.example.com", BearerToken: "token", QPS: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.Host != "https://api.example.com" || rc.BearerToken != "token" || rc.QPS != 50 {
+		t.Errorf("unexpected config: %+v", rc)
+	}
+}
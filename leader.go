@@ -0,0 +1,121 @@
+package kubegen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kleader "k8s.io/client-go/tools/leaderelection"
+	klock "k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseName     = "kube-gen"
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// leaderEvent is sent to the render loop when this replica becomes the
+// leader. It forces a render, since the output may have been written by a
+// previous leader.
+type leaderEvent struct{}
+
+// isLeader reports whether this replica should render the template. Without
+// leader election, every replica renders.
+func (g *generator) isLeader() bool {
+	return !g.Config.LeaderElect || g.leader.Load()
+}
+
+// newLeaderElector creates an elector that campaigns for the lease given in
+// the config. onStart is called each time this replica becomes the leader.
+func (g *generator) newLeaderElector(onStart func()) (*kleader.LeaderElector, error) {
+	id := g.Config.LeaderElectionID
+	if id == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error determining leader election identity: %w", err)
+		}
+		id = host
+	}
+	ns := g.Config.LeaderElectionNamespace
+	if ns == "" {
+		ns = currentNamespace()
+	}
+	name := g.Config.LeaderElectionName
+	if name == "" {
+		name = defaultLeaseName
+	}
+
+	lock := &klock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: ns, Name: name},
+		Client:     g.Client.CoordinationV1(),
+		LockConfig: klock.ResourceLockConfig{Identity: id},
+	}
+	log := g.logger().With("lease", ns+"/"+name, "identity", id)
+
+	return kleader.NewLeaderElector(kleader.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   durationOrDefault(g.Config.LeaseDuration, defaultLeaseDuration),
+		RenewDeadline:   durationOrDefault(g.Config.RenewDeadline, defaultRenewDeadline),
+		RetryPeriod:     durationOrDefault(g.Config.RetryPeriod, defaultRetryPeriod),
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: kleader.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				log.Info("became the leader")
+				g.leader.Store(true)
+				leaderGauge.Set(1)
+				onStart()
+			},
+			OnStoppedLeading: func() {
+				if g.leader.Swap(false) {
+					log.Info("lost leadership")
+				}
+				leaderGauge.Set(0)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					log.Info("following leader", "leader", identity)
+				}
+			},
+		},
+	})
+}
+
+// runLeaderElection campaigns for leadership until ctx is cancelled. After
+// losing the lease, this replica goes back to being a follower and keeps
+// campaigning.
+func (g *generator) runLeaderElection(ctx context.Context, onStart func()) error {
+	le, err := g.newLeaderElector(onStart)
+	if err != nil {
+		return err
+	}
+	g.Add(1)
+	go func() {
+		defer g.Done()
+		for ctx.Err() == nil {
+			le.Run(ctx)
+		}
+	}()
+	return nil
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// currentNamespace returns the namespace of the current pod when running in
+// a cluster, otherwise the default namespace
+func currentNamespace() string {
+	if ns, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return metav1.NamespaceDefault
+}
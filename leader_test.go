@@ -0,0 +1,144 @@
+package kubegen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func leaderConfig(id string) Config {
+	return Config{
+		Watch:                   true,
+		LeaderElect:             true,
+		LeaderElectionNamespace: "default",
+		LeaderElectionID:        id,
+		LeaseDuration:           time.Second,
+		RenewDeadline:           500 * time.Millisecond,
+		RetryPeriod:             100 * time.Millisecond,
+	}
+}
+
+func TestLeaderElectionFailover(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	newGenerator := func(id string) *generator {
+		return &generator{Config: leaderConfig(id), Client: client}
+	}
+	a, b := newGenerator("a"), newGenerator("b")
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	if err := a.runLeaderElection(ctxA, func() {}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, 5*time.Second, a.isLeader)
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	if err := b.runLeaderElection(ctxB, func() {}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if b.isLeader() {
+		t.Fatal("expected b to be a follower while a holds the lease")
+	}
+
+	// a releases the lease when it shuts down
+	cancelA()
+	a.Wait()
+	if a.isLeader() {
+		t.Error("expected a to give up leadership")
+	}
+	waitFor(t, 5*time.Second, b.isLeader)
+
+	cancelB()
+	b.Wait()
+}
+
+func TestIsLeaderWithoutElection(t *testing.T) {
+	g := &generator{}
+	if !g.isLeader() {
+		t.Error("expected every replica to be the leader without leader election")
+	}
+}
+
+func TestWatchEventsFollower(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	holdLease(t, client, "other")
+
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	c := leaderConfig("me")
+	c.TemplateString = "rendered"
+	c.Output = out
+	g := &generator{
+		Config:    c,
+		Client:    client,
+		loadSvcs:  true,
+		notifiers: []notifier{n},
+	}
+
+	if err := g.watchEvents(watchContext(t, g)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if c := n.count.Load(); c != 0 {
+		t.Fatalf("expected a follower not to render, got %d renders", c)
+	}
+
+	// the other replica never renews its lease, so it eventually expires
+	waitFor(t, 5*time.Second, func() bool { return n.count.Load() > 0 })
+	if b, _ := os.ReadFile(out); string(b) != "rendered" {
+		t.Errorf("expected output [rendered], got [%s]", b)
+	}
+}
+
+func TestExecuteAfterLosingLeadership(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	n := &countingNotifier{}
+	c := leaderConfig("me")
+	c.TemplateString = "rendered"
+	c.Output = out
+	g := &generator{
+		Config:    c,
+		Client:    fake.NewSimpleClientset(),
+		loadSvcs:  true,
+		notifiers: []notifier{n},
+	}
+
+	// the lease was lost after the render started
+	if err := g.execute(context.Background(), true, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected the output not to be written, got %v", err)
+	}
+	if c := n.count.Load(); c != 0 {
+		t.Errorf("expected no notifications, got %d", c)
+	}
+}
+
+// holdLease creates the default lease, held by the given identity
+func holdLease(t *testing.T, client kclient.Interface, holder string) {
+	t.Helper()
+	duration := int32(1)
+	now := metav1.NewMicroTime(time.Now())
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: defaultLeaseName},
+		Spec: coordv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+	if _, err := client.CoordinationV1().Leases("default").Create(context.Background(), lease, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
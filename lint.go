@@ -0,0 +1,370 @@
+package kubegen
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// functions provided by text/template
+var builtinFuncs = map[string]reflect.Type{
+	"and":      nil,
+	"call":     nil,
+	"html":     reflect.TypeOf(""),
+	"index":    nil,
+	"slice":    nil,
+	"js":       reflect.TypeOf(""),
+	"len":      reflect.TypeOf(0),
+	"not":      reflect.TypeOf(true),
+	"or":       nil,
+	"print":    reflect.TypeOf(""),
+	"printf":   reflect.TypeOf(""),
+	"println":  reflect.TypeOf(""),
+	"urlquery": reflect.TypeOf(""),
+	"eq":       reflect.TypeOf(true),
+	"ge":       reflect.TypeOf(true),
+	"gt":       reflect.TypeOf(true),
+	"le":       reflect.TypeOf(true),
+	"lt":       reflect.TypeOf(true),
+	"ne":       reflect.TypeOf(true),
+}
+
+// matches errors returned by the template parser
+var parseErrorRegexp = regexp.MustCompile(`^template: (.*?):(\d+): (.*)$`)
+
+// Diagnostic describes a problem found in a template
+type Diagnostic struct {
+	Template string `json:"template"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Col == 0 {
+		return fmt.Sprintf("%s:%d: %s", d.Template, d.Line, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.Template, d.Line, d.Col, d.Message)
+}
+
+// LintTemplateFile checks the template at path for syntax errors, unknown
+// functions, and references to fields that don't exist in the Context.
+// Fields are only checked where their type can be determined statically, so
+// e.g. the results of functions returning any are not checked.
+func LintTemplateFile(path string) ([]Diagnostic, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return lintTemplate(path, string(text)), nil
+}
+
+func lintTemplate(name, text string) []Diagnostic {
+	trees := map[string]*parse.Tree{}
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+	if _, err := t.Parse(text, "", "", trees); err != nil {
+		return []Diagnostic{parseErrorDiagnostic(name, err)}
+	}
+
+	l := &linter{}
+	for tname, tree := range trees {
+		l.tree = tree
+		l.assigned = map[string]bool{}
+		l.findAssigned(tree.Root)
+		// the main template is executed with the Context, but the type of
+		// the data passed to other templates isn't known
+		var dot reflect.Type
+		if tname == name {
+			dot = reflect.TypeOf(Context{})
+		}
+		l.walk(tree.Root, dot, map[string]reflect.Type{"$": dot})
+	}
+
+	sort.Slice(l.diags, func(i, j int) bool {
+		a, b := l.diags[i], l.diags[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return l.diags
+}
+
+func parseErrorDiagnostic(name string, err error) Diagnostic {
+	d := Diagnostic{Template: name, Message: err.Error()}
+	if m := parseErrorRegexp.FindStringSubmatch(err.Error()); m != nil {
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Message = m[3]
+	}
+	return d
+}
+
+type linter struct {
+	tree  *parse.Tree
+	diags []Diagnostic
+	// variables of tree that are assigned with =. Variables are dynamically
+	// typed, so the type of these is unknown in every scope.
+	assigned map[string]bool
+}
+
+func (l *linter) report(n parse.Node, format string, args ...any) {
+	d := Diagnostic{Template: l.tree.ParseName, Message: fmt.Sprintf(format, args...)}
+	loc, _ := l.tree.ErrorContext(n)
+	// loc is name:line:col, with a zero-based column
+	parts := strings.Split(loc, ":")
+	if len(parts) >= 3 {
+		d.Line, _ = strconv.Atoi(parts[len(parts)-2])
+		d.Col, _ = strconv.Atoi(parts[len(parts)-1])
+		d.Col++
+	}
+	l.diags = append(l.diags, d)
+}
+
+// walk checks node, where dot has the given type. A nil type is unknown, and
+// disables checks of fields accessed through it.
+func (l *linter) walk(node parse.Node, dot reflect.Type, vars map[string]reflect.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			l.walk(c, dot, vars)
+		}
+	case *parse.ActionNode:
+		l.pipe(n.Pipe, dot, vars)
+	case *parse.IfNode:
+		inner := copyVars(vars)
+		l.pipe(n.Pipe, dot, inner)
+		l.walk(n.List, dot, inner)
+		l.walk(n.ElseList, dot, copyVars(vars))
+	case *parse.WithNode:
+		inner := copyVars(vars)
+		t := l.pipe(n.Pipe, dot, inner)
+		l.walk(n.List, t, inner)
+		l.walk(n.ElseList, dot, copyVars(vars))
+	case *parse.RangeNode:
+		inner := copyVars(vars)
+		// the range declarations are assigned below, not the result of the
+		// pipeline
+		key, elem := rangeTypes(l.pipe(n.Pipe, dot, copyVars(vars)))
+		switch len(n.Pipe.Decl) {
+		case 1:
+			inner[n.Pipe.Decl[0].Ident[0]] = elem
+		case 2:
+			inner[n.Pipe.Decl[0].Ident[0]] = key
+			inner[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+		l.walk(n.List, elem, inner)
+		l.walk(n.ElseList, dot, copyVars(vars))
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			l.pipe(n.Pipe, dot, copyVars(vars))
+		}
+	}
+}
+
+// pipe checks a pipeline and returns the type of its result. Variables it
+// declares are added to vars.
+func (l *linter) pipe(p *parse.PipeNode, dot reflect.Type, vars map[string]reflect.Type) reflect.Type {
+	var t reflect.Type
+	for _, c := range p.Cmds {
+		t = l.command(c, dot, vars)
+	}
+	for _, d := range p.Decl {
+		if p.IsAssign || l.assigned[d.Ident[0]] {
+			vars[d.Ident[0]] = nil
+		} else {
+			vars[d.Ident[0]] = t
+		}
+	}
+	return t
+}
+
+// findAssigned adds the variables assigned with = in node to l.assigned
+func (l *linter) findAssigned(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			l.findAssigned(c)
+		}
+	case *parse.ActionNode:
+		l.findAssigned(n.Pipe)
+	case *parse.IfNode:
+		l.findAssigned(&n.BranchNode)
+	case *parse.WithNode:
+		l.findAssigned(&n.BranchNode)
+	case *parse.RangeNode:
+		l.findAssigned(&n.BranchNode)
+	case *parse.BranchNode:
+		l.findAssigned(n.Pipe)
+		l.findAssigned(n.List)
+		l.findAssigned(n.ElseList)
+	case *parse.TemplateNode:
+		l.findAssigned(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		if n.IsAssign {
+			for _, d := range n.Decl {
+				l.assigned[d.Ident[0]] = true
+			}
+		}
+		for _, c := range n.Cmds {
+			for _, arg := range c.Args {
+				l.findAssigned(arg)
+			}
+		}
+	}
+}
+
+func (l *linter) command(c *parse.CommandNode, dot reflect.Type, vars map[string]reflect.Type) reflect.Type {
+	var t reflect.Type
+	for i, arg := range c.Args {
+		at := l.arg(arg, dot, vars)
+		if i == 0 {
+			t = at
+		}
+	}
+	return t
+}
+
+// arg checks a single operand and returns its type
+func (l *linter) arg(n parse.Node, dot reflect.Type, vars map[string]reflect.Type) reflect.Type {
+	switch n := n.(type) {
+	case *parse.IdentifierNode:
+		if f, ok := Funcs[n.Ident]; ok {
+			return funcResultType(f)
+		}
+		if t, ok := builtinFuncs[n.Ident]; ok {
+			return t
+		}
+		l.report(n, "function %q not defined", n.Ident)
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return l.fields(n, dot, n.Ident)
+	case *parse.VariableNode:
+		return l.fields(n, vars[n.Ident[0]], n.Ident[1:])
+	case *parse.ChainNode:
+		return l.fields(n, l.arg(n.Node, dot, vars), n.Field)
+	case *parse.PipeNode:
+		return l.pipe(n, dot, copyVars(vars))
+	case *parse.StringNode:
+		return reflect.TypeOf("")
+	case *parse.BoolNode:
+		return reflect.TypeOf(true)
+	}
+	return nil
+}
+
+// fields resolves a chain of field or method names starting at t, reporting
+// the first name that doesn't exist
+func (l *linter) fields(n parse.Node, t reflect.Type, names []string) reflect.Type {
+	for _, name := range names {
+		if t == nil {
+			return nil
+		}
+		if m, ok := lookupMethod(t, name); ok {
+			t = methodResultType(m)
+			continue
+		}
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := t.FieldByName(name)
+			if !ok || !f.IsExported() {
+				l.report(n, "can't evaluate field %s in type %s", name, t)
+				return nil
+			}
+			t = f.Type
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return nil
+			}
+			t = t.Elem()
+		case reflect.Interface:
+			return nil
+		default:
+			l.report(n, "can't evaluate field %s in type %s", name, t)
+			return nil
+		}
+	}
+	return known(t)
+}
+
+func lookupMethod(t reflect.Type, name string) (reflect.Method, bool) {
+	if t.Kind() == reflect.Interface {
+		return t.MethodByName(name)
+	}
+	if m, ok := t.MethodByName(name); ok {
+		return m, true
+	}
+	if t.Kind() != reflect.Pointer {
+		return reflect.PointerTo(t).MethodByName(name)
+	}
+	return reflect.Method{}, false
+}
+
+func methodResultType(m reflect.Method) reflect.Type {
+	if m.Type.NumOut() == 0 {
+		return nil
+	}
+	return known(m.Type.Out(0))
+}
+
+func funcResultType(f any) reflect.Type {
+	t := reflect.TypeOf(f)
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() == 0 {
+		return nil
+	}
+	return known(t.Out(0))
+}
+
+// rangeTypes returns the key and element types of ranging over t
+func rangeTypes(t reflect.Type) (reflect.Type, reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), known(t.Elem())
+	case reflect.Map:
+		return known(t.Key()), known(t.Elem())
+	case reflect.Int:
+		return reflect.TypeOf(0), nil
+	}
+	return nil, nil
+}
+
+// known returns t, or nil if t is an interface, whose dynamic type is
+// unknown
+func known(t reflect.Type) reflect.Type {
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	return t
+}
+
+func copyVars(vars map[string]reflect.Type) map[string]reflect.Type {
+	c := make(map[string]reflect.Type, len(vars))
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
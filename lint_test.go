@@ -0,0 +1,62 @@
+package kubegen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLintTemplate(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{`{{ range .Pods }}{{ .Name }} {{ .Status.PodIP }}{{ end }}`, nil},
+		{`{{ range $i, $p := readyPods .Pods }}{{ $i }} {{ $p.Spec.NodeName }}{{ end }}`, nil},
+		{`{{ with .Env }}{{ .HOME }}{{ end }} {{ $.Services }}`, nil},
+		{`{{ range .Services }}{{ .ObjectMeta.Labels.app }}{{ end }}`, nil},
+		{`{{ range .Service }}{{ end }}`, []string{"t:1:10: can't evaluate field Service in type kubegen.Context"}},
+		{`{{ frobnicate . }}`, []string{`t:1:4: function "frobnicate" not defined`}},
+		{"\n{{ range .Endpoints }}{{ range .Subsets }}{{ .Adresses }}{{ end }}{{ end }}", []string{
+			"t:2:46: can't evaluate field Adresses in type v1.EndpointSubset",
+		}},
+		{`{{ $svcs := .Services }}{{ range $svcs }}{{ .Spec.Port }}{{ end }}`, []string{
+			"t:1:50: can't evaluate field Port in type v1.ServiceSpec",
+		}},
+		{`{{ len .Pods | printf "%d" }}{{ (len .Pods).Count }}`, []string{"t:1:44: can't evaluate field Count in type int"}},
+		// the types of values returned as any, and passed to other templates,
+		// aren't known
+		{`{{ range where .Pods "Name" "x" }}{{ .Anything }}{{ end }}`, nil},
+		{`{{ define "x" }}{{ .Anything }}{{ end }}{{ template "x" .Pods }}`, nil},
+		{`{{ range .Pods }}`, []string{"t:1: unexpected EOF"}},
+		// variables are dynamically typed, so reassigned variables are unknown,
+		// including before the assignment in a loop
+		{`{{ $x := "" }}{{ range .Pods }}{{ $x = . }}{{ end }}{{ $x.Name }}`, nil},
+		{`{{ $x := "" }}{{ range .Pods }}{{ if $x }}{{ $x.Name }}{{ end }}{{ $x = . }}{{ end }}`, nil},
+		{`{{ $x := "" }}{{ $y := .Pods }}{{ with .Services }}{{ $x = . }}{{ end }}{{ $y.Count }}`, []string{
+			"t:1:78: can't evaluate field Count in type []v1.Pod",
+		}},
+	}
+
+	for i, c := range cases {
+		var got []string
+		for _, d := range lintTemplate("t", c.input) {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("case %d failed: got %q expected %q", i, got, c.expected)
+		}
+	}
+}
+
+func TestLintTemplateFile(t *testing.T) {
+	diags, err := LintTemplateFile("testdata/cases/upstreams/template.tmpl")
+	if err != nil || len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v %v", diags, err)
+	}
+
+	if _, err := LintTemplateFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package kubegen
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	kapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// file extensions read from manifest directories
+var manifestExts = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// loadManifests builds a Context from the objects in the given files,
+// directories (read recursively) or - for stdin. Files may contain multiple
+// YAML documents, and List objects such as the output of kubectl get -o yaml.
+// Objects of types other than pods, services and endpoints are ignored.
+func loadManifests(paths []string) (*Context, error) {
+	ctx := &Context{}
+	for _, p := range paths {
+		if p == "-" {
+			if err := ctx.addManifests("stdin", os.Stdin); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// files in directories are filtered by extension, but files
+			// given explicitly are always read
+			if d.IsDir() || (path != p && !manifestExts[strings.ToLower(filepath.Ext(path))]) {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return ctx.addManifests(path, f)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error loading manifests: %w", err)
+		}
+	}
+	return ctx, nil
+}
+
+// addManifests decodes the objects read from r and adds them to the Context
+func (ctx *Context) addManifests(name string, r io.Reader) error {
+	dec := kyaml.NewYAMLOrJSONDecoder(bufio.NewReader(r), 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error decoding %s: %w", name, err)
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw.Raw), []byte("null")) {
+			// empty YAML document
+			continue
+		}
+		if err := ctx.addObject(name, raw.Raw); err != nil {
+			return err
+		}
+	}
+}
+
+func (ctx *Context) addObject(name string, data []byte) error {
+	obj, gvk, err := kscheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			slog.Debug("skipping unsupported object in manifest", "file", name, "error", err)
+			return nil
+		}
+		return fmt.Errorf("error decoding %s: %w", name, err)
+	}
+
+	switch o := obj.(type) {
+	case *kapi.Pod:
+		ctx.Pods = append(ctx.Pods, *o)
+	case *kapi.Service:
+		ctx.Services = append(ctx.Services, *o)
+	case *kapi.Endpoints:
+		ctx.Endpoints = append(ctx.Endpoints, *o)
+	case *kapi.PodList:
+		ctx.Pods = append(ctx.Pods, o.Items...)
+	case *kapi.ServiceList:
+		ctx.Services = append(ctx.Services, o.Items...)
+	case *kapi.EndpointsList:
+		ctx.Endpoints = append(ctx.Endpoints, o.Items...)
+	case *kapi.List:
+		// items of a generic list may be of any type
+		for _, item := range o.Items {
+			if err := ctx.addObject(name, item.Raw); err != nil {
+				return err
+			}
+		}
+	default:
+		slog.Debug("skipping unsupported object in manifest", "file", name, "kind", gvk.Kind)
+	}
+	return nil
+}
+
+// filter removes the resource types that aren't loaded by the generator, and
+// pods that aren't on the configured node. Objects loaded from the API server
+// are already filtered by the server, but manifests and snapshots are not.
+func (ctx *Context) filter(g *generator) {
+	if !g.loadPods {
+		ctx.Pods = nil
+	} else if g.Config.Node != "" {
+		var pods []kapi.Pod
+		for _, p := range ctx.Pods {
+			if p.Spec.NodeName == g.Config.Node {
+				pods = append(pods, p)
+			}
+		}
+		ctx.Pods = pods
+	}
+	if !g.loadSvcs {
+		ctx.Services = nil
+	}
+	if !g.loadEps {
+		ctx.Endpoints = nil
+	}
+	for _, c := range ctx.Clusters {
+		c.filter(g)
+	}
+}
@@ -0,0 +1,110 @@
+package kubegen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func podNames(ctx *Context) []string {
+	var names []string
+	for _, p := range ctx.Pods {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestLoadManifests(t *testing.T) {
+	ctx, err := loadManifests([]string{"testdata/cases/upstreams/manifests"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := podNames(ctx); !reflect.DeepEqual(names, []string{"web-1", "web-2"}) {
+		t.Errorf("unexpected pods: %v", names)
+	}
+	if len(ctx.Services) != 1 || ctx.Services[0].Name != "web" {
+		t.Errorf("unexpected services: %v", ctx.Services)
+	}
+	if len(ctx.Endpoints) != 1 || len(ctx.Endpoints[0].Subsets) != 1 {
+		t.Errorf("unexpected endpoints: %v", ctx.Endpoints)
+	}
+}
+
+func TestLoadManifestsTemplate(t *testing.T) {
+	ctx, err := loadManifests([]string{"testdata/cases/upstreams/manifests"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := execTemplateFile("testdata/cases/upstreams/template.tmpl", ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "upstream web {\n  server 10.0.0.1:80;\n}\n\n"
+	if string(out) != expected {
+		t.Errorf("expected [%s], got [%s]", expected, out)
+	}
+}
+
+func TestLoadManifestsErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("kind: Pod\napiVersion: v1\nspec: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{filepath.Join(dir, "missing"), "error loading manifests: "},
+		{invalid, "error loading manifests: error decoding " + invalid},
+	}
+
+	for i, c := range cases {
+		if _, err := loadManifests([]string{c.path}); err == nil || !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("case %d failed: got [%v] expected an error starting with [%s]", i, err, c.expected)
+		}
+	}
+}
+
+func TestGenerateFromManifests(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	g, err := NewGenerator(Config{
+		Manifests:      []string{"testdata/cases/upstreams/manifests/pods.yaml"},
+		TemplateString: `{{ range .Pods }}{{ .Name }} {{ end }}{{ len .Services }}`,
+		Output:         out,
+		ResourceTypes:  []string{"pods"},
+		Node:           "node-b",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := g.Generate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "web-2 0" {
+		t.Errorf("expected output [web-2 0], got [%s]", b)
+	}
+}
+
+func TestValidateConfigOffline(t *testing.T) {
+	cases := []struct {
+		config   Config
+		expected string
+	}{
+		{Config{Manifests: []string{"a"}, Watch: true}, "manifests cannot be watched"},
+		{Config{Manifests: []string{"a"}, Output: "configmap://ns/name/key"}, "configmap output requires an API server"},
+		{Config{Manifests: []string{"a"}, NotifyExec: "reload"}, "exec notifications require an API server"},
+		{Config{Replay: "a", Watch: true}, "snapshots cannot be watched"},
+		{Config{Replay: "a", Manifests: []string{"a"}}, "manifests and a snapshot cannot be rendered together"},
+		{Config{Replay: "a", Clusters: []ClusterConfig{{Name: "east"}}}, "snapshots cannot be combined with clusters"},
+	}
+
+	for i, c := range cases {
+		g := &generator{Config: c.config}
+		if err := g.validateConfig(); err == nil || err.Error() != c.expected {
+			t.Errorf("case %d failed: got [%v] expected [%s]", i, err, c.expected)
+		}
+	}
+}
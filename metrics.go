@@ -0,0 +1,93 @@
+package kubegen
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const metricsNamespace = "kubegen"
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	rendersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "renders_total",
+		Help:      "Number of renders by result (success, error or skipped).",
+	}, []string{"result"})
+	renderDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "render_duration_seconds",
+		Help:      "Time taken to load data, render the template, write the output and run commands.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	templateErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "template_errors_total",
+		Help:      "Number of errors parsing or executing the template.",
+	})
+	commandRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "command_runs_total",
+		Help:      "Number of pre/post command runs by exit code. Commands that were killed have an exit code of -1.",
+	}, []string{"command", "exit_code"})
+	commandFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "command_failures_total",
+		Help:      "Number of pre/post command runs that failed or timed out.",
+	}, []string{"command"})
+	debounceCoalescedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "debounce_coalesced_events_total",
+		Help:      "Number of events merged into a pending render by the debouncer.",
+	})
+	informerEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "informer_events_total",
+		Help:      "Number of events received from the Kubernetes API by resource and event type.",
+	}, []string{"resource", "type"})
+	lastWriteTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_successful_write_timestamp_seconds",
+		Help:      "Unix time of the last successful write of the output.",
+	})
+	leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "leader",
+		Help:      "1 if this replica holds the leader election lease, otherwise 0.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		rendersTotal,
+		renderDuration,
+		templateErrorsTotal,
+		commandRunsTotal,
+		commandFailuresTotal,
+		debounceCoalescedTotal,
+		informerEventsTotal,
+		lastWriteTimestamp,
+		leaderGauge,
+	)
+}
+
+// observeCommand records the result of running a pre/post command
+func observeCommand(name string, err error) {
+	code := 0
+	if err != nil {
+		code = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		commandFailuresTotal.WithLabelValues(name).Inc()
+	}
+	commandRunsTotal.WithLabelValues(name, strconv.Itoa(code)).Inc()
+}
@@ -0,0 +1,41 @@
+package kubegen
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	observeCommand("post", nil)
+	observeCommand("post", errors.New("timed out"))
+	informerEventsTotal.WithLabelValues("pods", "add").Inc()
+
+	srv := httptest.NewServer(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+
+	for _, expected := range []string{
+		`kubegen_command_runs_total{command="post",exit_code="0"}`,
+		`kubegen_command_runs_total{command="post",exit_code="-1"}`,
+		`kubegen_command_failures_total{command="post"}`,
+		`kubegen_informer_events_total{resource="pods",type="add"}`,
+		`kubegen_last_successful_write_timestamp_seconds`,
+		`kubegen_render_duration_seconds_bucket`,
+	} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected metrics output to contain %s", expected)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// renderResult describes output that has been successfully written
+type renderResult struct {
+	Output   string    `json:"output"`
+	Checksum string    `json:"checksum"`
+	Changed  bool      `json:"changed"`
+	Changes  []Change  `json:"changes,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+func newRenderResult(output string, content []byte) *renderResult {
+	sum := sha256.Sum256(content)
+	return &renderResult{
+		Output:   output,
+		Checksum: hex.EncodeToString(sum[:]),
+		Time:     time.Now(),
+	}
+}
+
+// notifier is informed after the output has been written
+type notifier interface {
+	notify(ctx context.Context, r *renderResult) error
+}
+
+func (g *generator) newNotifiers() ([]notifier, error) {
+	var (
+		c         = g.Config
+		notifiers []notifier
+	)
+	if c.NotifyPIDFile != "" || c.NotifyProcess != "" {
+		n, err := newSignalNotifier(c.NotifySignal, c.NotifyPIDFile, c.NotifyProcess, g.logger())
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	} else if c.NotifySignal != "" {
+		return nil, errors.New("a pid file or process name is required to send a notification signal")
+	}
+	for _, u := range c.NotifyURLs {
+		n, err := newWebhookNotifier(u, c, g.logger())
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if c.NotifyExec != "" {
+		n, err := newExecNotifier(g.Client, g.restConfig, c, g.logger())
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, nil
+}
+
+func (g *generator) notify(ctx context.Context, r *renderResult) error {
+	var errs []error
+	for _, n := range g.notifiers {
+		if err := n.notify(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// signalNotifier sends a signal to the process identified by a pid file, or
+// to all processes with a given name
+type signalNotifier struct {
+	sig     syscall.Signal
+	pidFile string
+	process string
+	log     *slog.Logger
+}
+
+func newSignalNotifier(sig, pidFile, process string, log *slog.Logger) (*signalNotifier, error) {
+	if sig == "" {
+		sig = "HUP"
+	}
+	s, err := parseSignal(sig)
+	if err != nil {
+		return nil, err
+	}
+	return &signalNotifier{
+		sig:     s,
+		pidFile: pidFile,
+		process: process,
+		log:     log,
+	}, nil
+}
+
+func (n *signalNotifier) notify(context.Context, *renderResult) error {
+	var (
+		pids []int
+		errs []error
+	)
+	if n.pidFile != "" {
+		if pid, err := readPIDFile(n.pidFile); err != nil {
+			errs = append(errs, err)
+		} else {
+			pids = append(pids, pid)
+		}
+	}
+	if n.process != "" {
+		if p, err := findPIDsByName(n.process); err != nil {
+			errs = append(errs, err)
+		} else if len(p) == 0 {
+			errs = append(errs, fmt.Errorf("no process named [%s] found", n.process))
+		} else {
+			pids = append(pids, p...)
+		}
+	}
+	errs = append(errs, n.signal(pids))
+	return errors.Join(errs...)
+}
+
+// signal sends the signal to every process in pids, even if signaling one of
+// them fails
+func (n *signalNotifier) signal(pids []int) error {
+	var errs []error
+	for _, pid := range pids {
+		n.log.Info("sending signal", "signal", n.sig, "pid", pid)
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error finding process %d: %w", pid, err))
+			continue
+		}
+		if err := p.Signal(n.sig); err != nil {
+			errs = append(errs, fmt.Errorf("error signaling process %d: %w", pid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseSignal parses a signal name (e.g. HUP or SIGHUP) or number
+func parseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("invalid signal: %s", s)
+}
+
+func readPIDFile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading pid file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid < 1 {
+		return 0, fmt.Errorf("invalid pid file [%s]: %q", path, b)
+	}
+	return pid, nil
+}
+
+// findPIDsByName scans /proc for processes whose command name or executable
+// matches name. The current process is never included.
+func findPIDsByName(name string) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %w", err)
+	}
+
+	var pids []int
+	self := os.Getpid()
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		// processes may exit while we're scanning, so errors are ignored
+		if comm, err := os.ReadFile(filepath.Join("/proc", e.Name(), "comm")); err == nil && strings.TrimSpace(string(comm)) == name {
+			pids = append(pids, pid)
+			continue
+		}
+		if cmdline, err := os.ReadFile(filepath.Join("/proc", e.Name(), "cmdline")); err == nil {
+			argv0, _, _ := bytes.Cut(cmdline, []byte{0})
+			if len(argv0) > 0 && filepath.Base(string(argv0)) == name {
+				pids = append(pids, pid)
+			}
+		}
+	}
+	return pids, nil
+}
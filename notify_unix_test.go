@@ -0,0 +1,161 @@
+//go:build !windows
+
+package kubegen
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const helperExitCode = 42
+
+// TestHelperProcess is not a real test. It is started as a child process by
+// the signal notification tests, and exits with helperExitCode when it
+// receives SIGHUP.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("KUBEGEN_HELPER_PROCESS") != "1" {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	fmt.Println("ready")
+	select {
+	case <-ch:
+		os.Exit(helperExitCode)
+	case <-time.After(10 * time.Second):
+		os.Exit(1)
+	}
+}
+
+func startHelperProcess(t *testing.T) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "KUBEGEN_HELPER_PROCESS=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill() //nolint:errcheck
+		cmd.Wait()         //nolint:errcheck
+	})
+
+	// wait for the signal handler to be installed
+	if s := bufio.NewScanner(stdout); !s.Scan() || s.Text() != "ready" {
+		t.Fatalf("helper process did not start: %v", s.Err())
+	}
+	return cmd
+}
+
+func TestParseSignal(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected syscall.Signal
+		err      error
+	}{
+		{"HUP", syscall.SIGHUP, nil},
+		{"SIGHUP", syscall.SIGHUP, nil},
+		{"usr1", syscall.SIGUSR1, nil},
+		{"15", syscall.SIGTERM, nil},
+		{"NOPE", 0, errors.New("invalid signal: NOPE")},
+		{"-1", 0, errors.New("invalid signal: -1")},
+	}
+
+	for i, c := range cases {
+		if sig, err := parseSignal(c.input); sig != c.expected || !reflect.DeepEqual(err, c.err) {
+			t.Errorf("case %d failed: got [%v, %v] expected [%v, %v]\n", i, sig, err, c.expected, c.err)
+		}
+	}
+}
+
+func TestSignalNotifierPIDFile(t *testing.T) {
+	cmd := startHelperProcess(t)
+
+	pidFile := filepath.Join(t.TempDir(), "helper.pid")
+	if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := newSignalNotifier("HUP", pidFile, "", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.notify(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); !errors.As(err, &exitErr) || exitErr.ExitCode() != helperExitCode {
+		t.Errorf("expected helper to exit with code %d after signal, got %v", helperExitCode, err)
+	}
+}
+
+func TestSignalNotifierMissingPIDFile(t *testing.T) {
+	n, err := newSignalNotifier("HUP", filepath.Join(t.TempDir(), "missing.pid"), "", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.notify(context.Background(), nil); err == nil {
+		t.Errorf("expected an error for a missing pid file")
+	}
+}
+
+func TestSignalNotifierSignalsAllPIDs(t *testing.T) {
+	// the pid of a process that has exited can't be signaled
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	cmd := startHelperProcess(t)
+
+	n, err := newSignalNotifier("HUP", "", "", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.signal([]int{exited.Process.Pid, cmd.Process.Pid}); err == nil {
+		t.Errorf("expected an error for the exited process")
+	}
+
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); !errors.As(err, &exitErr) || exitErr.ExitCode() != helperExitCode {
+		t.Errorf("expected helper to exit with code %d after signal, got %v", helperExitCode, err)
+	}
+}
+
+func TestFindPIDsByName(t *testing.T) {
+	if _, err := os.Stat("/proc/self/comm"); err != nil {
+		t.Skip("/proc is not available")
+	}
+	cmd := startHelperProcess(t)
+
+	// the helper is a copy of the test binary, so it shares our name
+	pids, err := findPIDsByName(filepath.Base(os.Args[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, pid := range pids {
+		if pid == os.Getpid() {
+			t.Errorf("findPIDsByName should not return the current process")
+		}
+		found = found || pid == cmd.Process.Pid
+	}
+	if !found {
+		t.Errorf("expected helper process %d in %v", cmd.Process.Pid, pids)
+	}
+}
@@ -0,0 +1,187 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapply "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+const (
+	configMapScheme = "configmap://"
+	secretScheme    = "secret://"
+
+	// prefix of the field managers used for server-side apply
+	fieldManagerPrefix = "kube-gen/"
+	// maximum length of a field manager name
+	maxFieldManager = 128
+)
+
+// objectOutput identifies a single key in a ConfigMap or Secret that
+// rendered content is written to
+type objectOutput struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (o *objectOutput) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", o.Kind, o.Namespace, o.Name, o.Key)
+}
+
+// parseObjectOutput parses output targets of the form configmap://ns/name/key
+// or secret://ns/name/key. Returns nil if the output is not an object target.
+func parseObjectOutput(output string) (*objectOutput, error) {
+	var kind, rest string
+	switch {
+	case strings.HasPrefix(output, configMapScheme):
+		kind, rest = "configmap", strings.TrimPrefix(output, configMapScheme)
+	case strings.HasPrefix(output, secretScheme):
+		kind, rest = "secret", strings.TrimPrefix(output, secretScheme)
+	default:
+		return nil, nil //nolint:nilnil
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid %s output [%s]: expected %s<namespace>/<name>/<key>", kind, output, kind+"://")
+	}
+	return &objectOutput{
+		Kind:      kind,
+		Namespace: parts[0],
+		Name:      parts[1],
+		Key:       parts[2],
+	}, nil
+}
+
+// writeOutput writes rendered content to the configured output target,
+// reporting whether the target was changed
+func (g *generator) writeOutput(ctx context.Context, content []byte) (bool, error) {
+	o, err := parseObjectOutput(g.Config.Output)
+	if err != nil {
+		return false, err
+	}
+
+	var changed bool
+	if o == nil {
+		changed, err = g.writeFile(content)
+	} else {
+		changed, err = g.writeObject(ctx, o, content)
+	}
+	if err == nil {
+		lastWriteTimestamp.SetToCurrentTime()
+	}
+	return changed, err
+}
+
+// outputChanged reports whether content differs from what is currently stored
+// in the output target. When writing to STDOUT, content is compared with the
+// previous render instead.
+func (g *generator) outputChanged(ctx context.Context, content []byte, checksum string) (bool, error) {
+	var (
+		oldContent []byte
+		exists     bool
+	)
+	o, err := parseObjectOutput(g.Config.Output)
+	switch {
+	case err != nil:
+		return false, err
+	case o != nil:
+		oldContent, exists, err = g.readObject(ctx, o)
+	case g.Config.Output != "":
+		if oldContent, err = os.ReadFile(g.Config.Output); err == nil {
+			exists = true
+		} else if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	default:
+		return checksum != g.lastChecksum, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error comparing old version: %w", err)
+	}
+	return !exists || !bytes.Equal(oldContent, content), nil
+}
+
+// readObject returns the current value of the key of a ConfigMap or Secret
+func (g *generator) readObject(ctx context.Context, o *objectOutput) ([]byte, bool, error) {
+	switch o.Kind {
+	case "configmap":
+		cm, err := g.Client.CoreV1().ConfigMaps(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		v, exists := cm.Data[o.Key]
+		return []byte(v), exists, nil
+	case "secret":
+		s, err := g.Client.CoreV1().Secrets(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		v, exists := s.Data[o.Key]
+		return v, exists, nil
+	}
+	return nil, false, fmt.Errorf("invalid output kind: %s", o.Kind)
+}
+
+// writeObject creates or updates the key of a ConfigMap or Secret using
+// server-side apply. The object is left untouched if the key already holds
+// the rendered content.
+// fieldManager returns the server-side apply field manager of the key. Apply
+// removes the fields its manager owned that aren't in the applied object, so
+// each key needs its own manager for outputs sharing an object to leave each
+// other's keys alone.
+func (o *objectOutput) fieldManager() string {
+	if m := fieldManagerPrefix + o.Key; len(m) <= maxFieldManager {
+		return m
+	}
+	sum := sha256.Sum256([]byte(o.Key))
+	return fieldManagerPrefix + hex.EncodeToString(sum[:])
+}
+
+func (g *generator) writeObject(ctx context.Context, o *objectOutput, content []byte) (bool, error) {
+	oldContent, exists, err := g.readObject(ctx, o)
+	if err != nil {
+		return false, fmt.Errorf("error comparing old version: %w", err)
+	}
+
+	if exists && bytes.Equal(oldContent, content) {
+		return false, nil
+	}
+
+	// Always overwrite in watch mode - doesn't make sense
+	// to watch and not overwrite
+	if exists && !g.Config.Watch && !g.Config.Overwrite {
+		return false, fmt.Errorf("output key already exists")
+	}
+
+	opts := metav1.ApplyOptions{FieldManager: o.fieldManager(), Force: true}
+	switch o.Kind {
+	case "configmap":
+		cm := kapply.ConfigMap(o.Name, o.Namespace).WithData(map[string]string{o.Key: string(content)})
+		if _, err := g.Client.CoreV1().ConfigMaps(o.Namespace).Apply(ctx, cm, opts); err != nil {
+			return false, fmt.Errorf("error applying configmap: %w", err)
+		}
+	case "secret":
+		s := kapply.Secret(o.Name, o.Namespace).WithData(map[string][]byte{o.Key: content})
+		if _, err := g.Client.CoreV1().Secrets(o.Namespace).Apply(ctx, s, opts); err != nil {
+			return false, fmt.Errorf("error applying secret: %w", err)
+		}
+	}
+	g.logger().Info("output updated", "kind", o.Kind, "namespace", o.Namespace, "name", o.Name, "key", o.Key)
+	return true, nil
+}
@@ -0,0 +1,214 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	krest "k8s.io/client-go/rest"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestParseObjectOutput(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected *objectOutput
+		err      error
+	}{
+		{"", nil, nil},
+		{"/etc/nginx/nginx.conf", nil, nil},
+		{"configmap://default/nginx/nginx.conf", &objectOutput{"configmap", "default", "nginx", "nginx.conf"}, nil},
+		{"secret://kube-system/certs/tls.crt", &objectOutput{"secret", "kube-system", "certs", "tls.crt"}, nil},
+		{"configmap://default/nginx", nil, errors.New("invalid configmap output [configmap://default/nginx]: expected configmap://<namespace>/<name>/<key>")},
+		{"secret:///certs/tls.crt", nil, errors.New("invalid secret output [secret:///certs/tls.crt]: expected secret://<namespace>/<name>/<key>")},
+	}
+
+	for i, c := range cases {
+		o, err := parseObjectOutput(c.input)
+		if !reflect.DeepEqual(o, c.expected) || !reflect.DeepEqual(err, c.err) {
+			t.Errorf("case %d failed: got [%#v, %v] expected [%#v, %v]\n", i, o, err, c.expected, c.err)
+		}
+	}
+}
+
+// newApplyRecorder returns a fake clientset that records server-side apply
+// patches, which the fake object tracker does not support
+func newApplyRecorder(objects ...runtime.Object) (*fake.Clientset, *[]ktesting.PatchAction) {
+	var patches []ktesting.PatchAction
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("patch", "*", func(action ktesting.Action) (bool, runtime.Object, error) {
+		patches = append(patches, action.(ktesting.PatchAction))
+		return true, nil, nil
+	})
+	return client, &patches
+}
+
+func TestWriteObject(t *testing.T) {
+	existing := &kapi.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nginx"},
+		Data:       map[string]string{"nginx.conf": "unchanged"},
+	}
+
+	cases := []struct {
+		output  string
+		content string
+		config  Config
+		patches int
+		err     error
+	}{
+		{"configmap://default/nginx/nginx.conf", "unchanged", Config{}, 0, nil},
+		{"configmap://default/nginx/nginx.conf", "changed", Config{Overwrite: true}, 1, nil},
+		{"configmap://default/nginx/nginx.conf", "changed", Config{}, 0, errors.New("output key already exists")},
+		{"configmap://default/nginx/nginx.conf", "changed", Config{Watch: true}, 1, nil},
+		{"configmap://default/nginx/other.conf", "new", Config{}, 1, nil},
+		{"configmap://default/missing/nginx.conf", "new", Config{}, 1, nil},
+		{"secret://default/missing/tls.crt", "new", Config{}, 1, nil},
+	}
+
+	for i, c := range cases {
+		client, patches := newApplyRecorder(existing)
+		c.config.Output = c.output
+		g := &generator{Config: c.config, Client: client}
+
+		changed, err := g.writeOutput(context.Background(), []byte(c.content))
+		if !reflect.DeepEqual(err, c.err) {
+			t.Errorf("case %d failed: got error [%v] expected [%v]\n", i, err, c.err)
+		}
+		if changed != (c.patches > 0) {
+			t.Errorf("case %d failed: got changed [%v] expected [%v]\n", i, changed, c.patches > 0)
+		}
+		if len(*patches) != c.patches {
+			t.Errorf("case %d failed: got %d patches expected %d\n", i, len(*patches), c.patches)
+		}
+	}
+}
+
+func TestWriteObjectLeavesOtherKeys(t *testing.T) {
+	client, patches := newApplyRecorder()
+	g := &generator{Config: Config{Output: "configmap://default/nginx/nginx.conf"}, Client: client}
+	if _, err := g.writeOutput(context.Background(), []byte("content")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*patches) != 1 {
+		t.Fatalf("expected 1 patch, got %d", len(*patches))
+	}
+
+	expected := `{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"nginx","namespace":"default"},"data":{"nginx.conf":"content"}}`
+	if p := (*patches)[0]; string(p.GetPatch()) != expected {
+		t.Errorf("unexpected apply patch. Expected [%s] got [%s]\n", expected, p.GetPatch())
+	}
+}
+
+func TestWriteObjectFieldManagers(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		managers = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPatch {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			return
+		}
+		var cm kapi.ConfigMap
+		if err := json.NewDecoder(r.Body).Decode(&cm); err != nil {
+			t.Errorf("invalid patch: %v", err)
+		}
+		mu.Lock()
+		for key := range cm.Data {
+			managers[key] = r.URL.Query().Get("fieldManager")
+		}
+		mu.Unlock()
+		json.NewEncoder(w).Encode(cm) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	client, err := kclient.NewForConfig(&krest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		g := &generator{Config: Config{Output: "configmap://default/cfg/" + key}, Client: client}
+		if _, err := g.writeOutput(context.Background(), []byte("content")); err != nil {
+			t.Fatalf("unexpected error writing %s: %v", key, err)
+		}
+	}
+
+	expected := map[string]string{"a": "kube-gen/a", "b": "kube-gen/b"}
+	if !reflect.DeepEqual(managers, expected) {
+		t.Errorf("expected field managers %v, got %v", expected, managers)
+	}
+
+	long := &objectOutput{Key: strings.Repeat("k", 200)}
+	if m := long.fieldManager(); len(m) > maxFieldManager || !strings.HasPrefix(m, fieldManagerPrefix) {
+		t.Errorf("invalid field manager for a long key: %s", m)
+	}
+}
+
+func TestWriteObjectLogsTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	client, _ := newApplyRecorder()
+	g := &generator{Config: Config{TemplatePath: "nginx.tmpl", Output: "configmap://default/nginx/nginx.conf"}, Client: client}
+	if _, err := g.writeOutput(context.Background(), []byte("content")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "msg=\"output updated\" template=nginx.tmpl"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected log to contain [%s], got [%s]", expected, buf.String())
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	g := &generator{Config: Config{Output: out, Overwrite: true}}
+
+	cases := []struct {
+		content string
+		changed bool
+	}{
+		{"first", true},
+		{"first", false},
+		{"second", true},
+	}
+
+	for i, c := range cases {
+		if changed, err := g.outputChanged(context.Background(), []byte(c.content), ""); err != nil || changed != c.changed {
+			t.Errorf("case %d failed: outputChanged returned [%v, %v] expected [%v]\n", i, changed, err, c.changed)
+		}
+		if changed, err := g.writeOutput(context.Background(), []byte(c.content)); err != nil || changed != c.changed {
+			t.Errorf("case %d failed: writeOutput returned [%v, %v] expected [%v]\n", i, changed, err, c.changed)
+		}
+		if b, _ := os.ReadFile(out); string(b) != c.content {
+			t.Errorf("case %d failed: file contains [%s] expected [%s]\n", i, b, c.content)
+		}
+	}
+}
+
+func TestOutputChangedStdout(t *testing.T) {
+	g := &generator{lastChecksum: "abc"}
+	if changed, _ := g.outputChanged(context.Background(), nil, "abc"); changed {
+		t.Errorf("expected output to be unchanged when the checksum matches the previous render")
+	}
+	if changed, _ := g.outputChanged(context.Background(), nil, "def"); !changed {
+		t.Errorf("expected output to be changed when the checksum differs from the previous render")
+	}
+}
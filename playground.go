@@ -0,0 +1,266 @@
+package kubegen
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// name of templates rendered by the playground
+const playgroundTemplate = "playground"
+
+// maximum size of a template sent to the playground
+const maxPlaygroundTemplate = 1 << 20
+
+//go:embed playground.html
+var playgroundPage []byte
+
+// functions that run commands or read the local filesystem, which are disabled
+// in the playground unless PlaygroundOptions.AllowUnsafeFuncs is set
+var unsafePlaygroundFuncs = []string{"dir", "exists", "shell"}
+
+// matches errors returned when executing a template
+var execErrorRegexp = regexp.MustCompile(`^template: [^:]*:(\d+):(\d+): (.*)$`)
+
+// Playground is an http.Handler serving a web page for editing a template
+// and viewing its output. Templates are rendered against a Context that is
+// loaded when the playground is created, and reloaded on request.
+type Playground struct {
+	g *generator
+
+	// host and port of the address the playground is served on
+	host, port string
+	// replaces the unsafe functions, unless they're allowed
+	funcs template.FuncMap
+
+	mu     sync.RWMutex
+	ctx    *Context
+	loaded time.Time
+
+	mux *http.ServeMux
+}
+
+// PlaygroundOptions configures how a Playground is served
+type PlaygroundOptions struct {
+	// Addr is the host:port the playground is served on. Requests whose Host
+	// or Origin header names another address are rejected, so that other
+	// sites can't use a browser to send requests to the playground. localhost
+	// and IP addresses are accepted with the same port.
+	Addr string
+	// AllowUnsafeFuncs enables functions that run commands or read the local
+	// filesystem, such as shell
+	AllowUnsafeFuncs bool
+}
+
+type playgroundRenderRequest struct {
+	Template string `json:"template"`
+}
+
+type playgroundRenderResponse struct {
+	// Rendered is false if the template couldn't be parsed or executed
+	Rendered    bool         `json:"rendered"`
+	Output      string       `json:"output"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type playgroundContextResponse struct {
+	Pods      int       `json:"pods"`
+	Services  int       `json:"services"`
+	Endpoints int       `json:"endpoints"`
+	Loaded    time.Time `json:"loaded"`
+	Template  string    `json:"template"`
+}
+
+// NewPlayground creates a Playground rendering the objects selected by c,
+// which are loaded from the API server, manifests or a snapshot. The
+// TemplateString of c is shown when the page is first opened.
+func NewPlayground(c Config, opts PlaygroundOptions) (*Playground, error) {
+	host, port, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid playground address: %w", err)
+	}
+	c.Watch = false
+	gen, err := NewGenerator(c)
+	if err != nil {
+		return nil, err
+	}
+	p := &Playground{g: gen.(*generator), host: host, port: port, mux: http.NewServeMux()}
+	if !opts.AllowUnsafeFuncs {
+		p.funcs = template.FuncMap{}
+		for _, name := range unsafePlaygroundFuncs {
+			p.funcs[name] = disabledFunc(name)
+		}
+	}
+	if err := p.g.validateConfig(); err != nil {
+		return nil, err
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	p.mux.HandleFunc("GET /{$}", p.page)
+	p.mux.HandleFunc("GET /context", p.context)
+	p.mux.HandleFunc("POST /reload", p.reload)
+	p.mux.HandleFunc("POST /render", p.render)
+	return p, nil
+}
+
+// Reload loads the current state of the selected objects
+func (p *Playground) Reload() error {
+	return p.load(context.Background())
+}
+
+func (p *Playground) load(ctx context.Context) error {
+	tctx, err := p.g.loadContext(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx, p.loaded = tctx, time.Now()
+	return nil
+}
+
+func (p *Playground) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.allowedHost(r.Host) {
+		http.Error(w, "invalid host", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme != "http" || !p.allowedHost(u.Host) {
+			http.Error(w, "invalid origin", http.StatusForbidden)
+			return
+		}
+	}
+	// browsers can't send JSON to another site without a preflight request,
+	// which the playground doesn't answer
+	if r.Method == http.MethodPost {
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+			http.Error(w, "expected Content-Type application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+	p.mux.ServeHTTP(w, r)
+}
+
+// allowedHost returns true if hostport names the address the playground is
+// served on. A name other than localhost could be pointed at the playground
+// by another site, so only the name it's served on is accepted.
+func (p *Playground) allowedHost(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, "80"
+	}
+	if port != p.port {
+		return false
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.EqualFold(host, p.host) || strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil
+}
+
+func (p *Playground) page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(playgroundPage) //nolint:errcheck
+}
+
+func (p *Playground) context(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	resp := playgroundContextResponse{
+		Pods:      len(p.ctx.Pods),
+		Services:  len(p.ctx.Services),
+		Endpoints: len(p.ctx.Endpoints),
+		Loaded:    p.loaded,
+		Template:  p.g.Config.TemplateString,
+	}
+	p.mu.RUnlock()
+	writeJSON(w, resp)
+}
+
+func (p *Playground) reload(w http.ResponseWriter, r *http.Request) {
+	if err := p.load(r.Context()); err != nil {
+		slog.Error("error reloading playground", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.context(w, r)
+}
+
+func (p *Playground) render(w http.ResponseWriter, r *http.Request) {
+	var req playgroundRenderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPlaygroundTemplate)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.RLock()
+	ctx := p.ctx
+	p.mu.RUnlock()
+	writeJSON(w, renderPlayground(req.Template, ctx, p.funcs))
+}
+
+// renderPlayground renders text with ctx, replacing any functions in funcs,
+// and returns the output with the problems found by the linter. The linter
+// can report problems in templates that execute correctly, so only parse
+// errors stop the template from being executed.
+func renderPlayground(text string, ctx *Context, funcs template.FuncMap) playgroundRenderResponse {
+	tmpl, err := newTemplate(playgroundTemplate).Funcs(funcs).Parse(text)
+	if err != nil {
+		return playgroundRenderResponse{Diagnostics: []Diagnostic{parseErrorDiagnostic(playgroundTemplate, err)}}
+	}
+
+	var resp playgroundRenderResponse
+	out, err := execTemplate(tmpl, ctx)
+	if err == nil {
+		resp.Rendered, resp.Output = true, string(out)
+	} else {
+		resp.Diagnostics = append(resp.Diagnostics, execErrorDiagnostic(err))
+	}
+	// an execution error replaces the linter's report of the same problem
+	for _, d := range lintTemplate(playgroundTemplate, text) {
+		if err != nil && d.Line == resp.Diagnostics[0].Line && d.Col == resp.Diagnostics[0].Col {
+			continue
+		}
+		resp.Diagnostics = append(resp.Diagnostics, d)
+	}
+	return resp
+}
+
+// disabledFunc returns a template function that fails because the function
+// name is disabled
+func disabledFunc(name string) func(...any) (any, error) {
+	return func(...any) (any, error) {
+		return nil, fmt.Errorf("%s is disabled in the playground", name)
+	}
+}
+
+func execErrorDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Template: playgroundTemplate, Message: err.Error()}
+	if m := execErrorRegexp.FindStringSubmatch(err.Error()); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Col, _ = strconv.Atoi(m[2])
+		// the column is zero-based
+		d.Col++
+		d.Message = m[3]
+	}
+	return d
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
@@ -0,0 +1,191 @@
+package kubegen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlayground(t *testing.T) {
+	p, err := NewPlayground(Config{
+		Manifests:      []string{"testdata/cases/upstreams/manifests"},
+		TemplateString: "{{ len .Pods }}",
+	}, PlaygroundOptions{Addr: "example.com:80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "kube-gen playground") {
+		t.Errorf("expected the playground page, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, jsonRequest("/reload", ""))
+	var c playgroundContextResponse
+	if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Pods != 2 || c.Services != 1 || c.Endpoints != 1 || c.Template != "{{ len .Pods }}" {
+		t.Errorf("unexpected context: %+v", c)
+	}
+
+	cases := []struct {
+		template string
+		expected playgroundRenderResponse
+	}{
+		{
+			template: "{{ range .Services }}{{ .Name }}{{ end }}",
+			expected: playgroundRenderResponse{Rendered: true, Output: "web"},
+		},
+		{
+			template: "{{ range .Services }}\n{{ .Nmae }}{{ end }}",
+			expected: playgroundRenderResponse{Diagnostics: []Diagnostic{
+				{Template: playgroundTemplate, Line: 2, Col: 4, Message: `executing "playground" at <.Nmae>: can't evaluate field Nmae in type v1.Service`},
+			}},
+		},
+		// problems found by the linter don't stop the template from being
+		// executed
+		{
+			template: `{{ if false }}{{ .Nope }}{{ end }}ok`,
+			expected: playgroundRenderResponse{Rendered: true, Output: "ok", Diagnostics: []Diagnostic{
+				{Template: playgroundTemplate, Line: 1, Col: 18, Message: "can't evaluate field Nope in type kubegen.Context"},
+			}},
+		},
+		{
+			template: `{{ $x := "" }}{{ range .Pods }}{{ $x = . }}{{ end }}{{ $x.Name }}`,
+			expected: playgroundRenderResponse{Rendered: true, Output: "web-2"},
+		},
+		{
+			template: "{{ if }}",
+			expected: playgroundRenderResponse{Diagnostics: []Diagnostic{
+				{Template: playgroundTemplate, Line: 1, Message: "missing value for if"},
+			}},
+		},
+		{
+			template: "ok\n  {{ index .Pods 5 }}",
+			expected: playgroundRenderResponse{Diagnostics: []Diagnostic{
+				{Template: playgroundTemplate, Line: 2, Col: 6, Message: `executing "playground" at <index .Pods 5>: error calling index: index out of range: 5`},
+			}},
+		},
+	}
+
+	for _, c := range cases {
+		body, _ := json.Marshal(playgroundRenderRequest{Template: c.template})
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, jsonRequest("/render", string(body)))
+		var got playgroundRenderResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%q: expected %+v, got %+v", c.template, c.expected, got)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, jsonRequest("/render", "{"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func jsonRequest(target, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestPlaygroundRejectsCrossSiteRequests(t *testing.T) {
+	p, err := NewPlayground(Config{
+		Manifests: []string{"testdata/cases/upstreams/manifests"},
+	}, PlaygroundOptions{Addr: "localhost:8090"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		contentType string
+		expected    int
+	}{
+		{name: "page", method: http.MethodGet, host: "localhost:8090", expected: http.StatusOK},
+		{name: "ip", method: http.MethodGet, host: "127.0.0.1:8090", expected: http.StatusOK},
+		{name: "ipv6", method: http.MethodGet, host: "[::1]:8090", expected: http.StatusOK},
+		{name: "render", method: http.MethodPost, host: "localhost:8090", origin: "http://localhost:8090", contentType: "application/json; charset=utf-8", expected: http.StatusOK},
+		{name: "other host", method: http.MethodGet, host: "attacker.example:8090", expected: http.StatusForbidden},
+		{name: "other port", method: http.MethodGet, host: "localhost:8091", expected: http.StatusForbidden},
+		{name: "no port", method: http.MethodGet, host: "localhost", expected: http.StatusForbidden},
+		{name: "other origin", method: http.MethodPost, host: "localhost:8090", origin: "http://attacker.example", contentType: "application/json", expected: http.StatusForbidden},
+		{name: "null origin", method: http.MethodPost, host: "localhost:8090", origin: "null", contentType: "application/json", expected: http.StatusForbidden},
+		{name: "form", method: http.MethodPost, host: "localhost:8090", contentType: "application/x-www-form-urlencoded", expected: http.StatusUnsupportedMediaType},
+		{name: "text", method: http.MethodPost, host: "localhost:8090", contentType: "text/plain", expected: http.StatusUnsupportedMediaType},
+		{name: "no content type", method: http.MethodPost, host: "localhost:8090", expected: http.StatusUnsupportedMediaType},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			target, body := "/", ""
+			if c.method == http.MethodPost {
+				target, body = "/render", `{"template": "{{ len .Pods }}"}`
+			}
+			r := httptest.NewRequest(c.method, target, strings.NewReader(body))
+			r.Host = c.host
+			if c.origin != "" {
+				r.Header.Set("Origin", c.origin)
+			}
+			if c.contentType != "" {
+				r.Header.Set("Content-Type", c.contentType)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, r)
+			if rec.Code != c.expected {
+				t.Errorf("expected status %d, got %d: %s", c.expected, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPlaygroundUnsafeFuncs(t *testing.T) {
+	const tmpl = `{{ exists "playground.go" }}`
+	cases := []struct {
+		allow    bool
+		expected playgroundRenderResponse
+	}{
+		{
+			expected: playgroundRenderResponse{Diagnostics: []Diagnostic{
+				{Template: playgroundTemplate, Line: 1, Col: 4, Message: `executing "playground" at <exists "playground.go">: error calling exists: exists is disabled in the playground`},
+			}},
+		},
+		{
+			allow:    true,
+			expected: playgroundRenderResponse{Rendered: true, Output: "true"},
+		},
+	}
+
+	for _, c := range cases {
+		p, err := NewPlayground(Config{
+			Manifests: []string{"testdata/cases/upstreams/manifests"},
+		}, PlaygroundOptions{Addr: "example.com:80", AllowUnsafeFuncs: c.allow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(playgroundRenderRequest{Template: tmpl})
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, jsonRequest("/render", string(body)))
+		var got playgroundRenderResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("allow=%t: expected %+v, got %+v", c.allow, c.expected, got)
+		}
+	}
+}
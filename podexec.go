@@ -0,0 +1,263 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	kclient "k8s.io/client-go/kubernetes"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	krest "k8s.io/client-go/rest"
+	kremote "k8s.io/client-go/tools/remotecommand"
+	kspdy "k8s.io/client-go/transport/spdy"
+)
+
+const (
+	// file containing the namespace of the current pod when running in a cluster
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// default time an exec notification may run in each pod
+	defaultNotifyExecTimeout = time.Minute
+)
+
+// execNotifier runs a command in a container of the current pod, or of all
+// running pods matching a label selector, using the pods/exec subresource
+type execNotifier struct {
+	client    kclient.Interface
+	config    *krest.Config
+	command   []string
+	container string
+	selector  string
+	namespace string
+	pod       string
+	timeout   time.Duration
+	log       *slog.Logger
+}
+
+func newExecNotifier(client kclient.Interface, config *krest.Config, c Config, log *slog.Logger) (*execNotifier, error) {
+	command, err := splitCommand(c.NotifyExec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exec notification command: %w", err)
+	}
+	if len(command) == 0 {
+		return nil, errors.New("exec notification command is empty")
+	}
+	n := &execNotifier{
+		client:    client,
+		config:    config,
+		command:   command,
+		container: c.NotifyExecContainer,
+		selector:  c.NotifyExecSelector,
+		namespace: c.NotifyExecNamespace,
+		timeout:   durationOrDefault(c.NotifyExecTimeout, defaultNotifyExecTimeout),
+		log:       log,
+	}
+
+	if n.namespace == "" {
+		n.namespace = currentNamespace()
+	}
+	if n.selector == "" {
+		// the hostname of a pod is its name unless spec.hostname is set
+		pod, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error determining current pod: %w", err)
+		}
+		n.pod = pod
+	}
+	return n, nil
+}
+
+// targets returns the names of the pods the command should be run in
+func (n *execNotifier) targets(ctx context.Context) ([]string, error) {
+	if n.selector == "" {
+		return []string{n.pod}, nil
+	}
+
+	pods, err := n.client.CoreV1().Pods(n.namespace).List(ctx, metav1.ListOptions{LabelSelector: n.selector})
+	if err != nil {
+		return nil, fmt.Errorf("error loading pods for exec notification: %w", err)
+	}
+	var names []string
+	for _, p := range pods.Items {
+		if p.Status.Phase == kapi.PodRunning && p.DeletionTimestamp == nil {
+			names = append(names, p.Name)
+		}
+	}
+	return names, nil
+}
+
+func (n *execNotifier) notify(ctx context.Context, _ *renderResult) error {
+	pods, err := n.targets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pod := range pods {
+		if err := n.exec(ctx, pod); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *execNotifier) exec(ctx context.Context, pod string) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	n.log.Info("running command in pod", "cmd", n.command, "namespace", n.namespace, "name", pod, "container", n.container)
+	req := n.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(n.namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&kapi.PodExecOptions{
+			Container: n.container,
+			Command:   n.command,
+			Stdout:    true,
+			Stderr:    true,
+		}, kscheme.ParameterCodec)
+
+	transport, upgrader, err := kspdy.RoundTripperFor(n.config)
+	if err != nil {
+		return fmt.Errorf("error running command in pod %s/%s: %w", n.namespace, pod, err)
+	}
+	exec, err := kremote.NewSPDYExecutorForTransports(&contextRoundTripper{transport, ctx}, &contextUpgrader{upgrader, ctx},
+		"POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("error running command in pod %s/%s: %w", n.namespace, pod, err)
+	}
+
+	var out bytes.Buffer
+	if err := exec.Stream(kremote.StreamOptions{Stdout: &out, Stderr: &out}); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("error running command in pod %s/%s: timed out after %v: %s", n.namespace, pod, n.timeout, out.Bytes())
+		}
+		return fmt.Errorf("error running command in pod %s/%s: %w: %s", n.namespace, pod, err, out.Bytes())
+	}
+	return nil
+}
+
+// The executor of the client-go version in use can't be cancelled, so
+// contextRoundTripper and contextUpgrader tie the upgrade request and the
+// stream that follows it to a context instead
+
+// contextRoundTripper sends requests with a context. The SPDY round tripper
+// only uses the context to dial, and keeps waiting for the response after it
+// is done, so RoundTrip stops waiting itself, and closes the response if it
+// arrives later.
+type contextRoundTripper struct {
+	http.RoundTripper
+	ctx context.Context
+}
+
+type roundTripResult struct {
+	resp *http.Response
+	err  error
+}
+
+func (rt *contextRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ch := make(chan roundTripResult, 1)
+	go func() {
+		resp, err := rt.RoundTripper.RoundTrip(req.WithContext(rt.ctx))
+		ch <- roundTripResult{resp, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.resp, r.err
+	case <-rt.ctx.Done():
+		go func() {
+			if r := <-ch; r.resp != nil {
+				r.resp.Body.Close() //nolint:errcheck
+			}
+		}()
+		return nil, rt.ctx.Err()
+	}
+}
+
+// contextUpgrader closes the connections it creates when a context is done,
+// which ends any stream using them
+type contextUpgrader struct {
+	kspdy.Upgrader
+	ctx context.Context
+}
+
+func (u *contextUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(u.ctx, func() {
+		conn.Close() //nolint:errcheck
+	})
+	go func() {
+		<-conn.CloseChan()
+		stop()
+	}()
+	return conn, nil
+}
+
+// splitCommand splits s into arguments the way a POSIX shell would, without
+// expanding variables or globs. Quotes and backslashes may be used to include
+// spaces in arguments, e.g. sh -c "nginx -t && nginx -s reload".
+func splitCommand(s string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			// inside double quotes, a backslash only escapes characters
+			// that are special there
+			if quote == '"' && !strings.ContainsRune("\\\"$`", r) {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape: %s", s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,220 @@
+package kubegen
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	kclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	krest "k8s.io/client-go/rest"
+)
+
+func newPod(ns, name string, labels map[string]string, phase kapi.PodPhase) *kapi.Pod {
+	return &kapi.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: labels},
+		Status:     kapi.PodStatus{Phase: phase},
+	}
+}
+
+func TestExecNotifierTargets(t *testing.T) {
+	nginx := map[string]string{"app": "nginx"}
+	client := fake.NewSimpleClientset(
+		newPod("default", "nginx-1", nginx, kapi.PodRunning),
+		newPod("default", "nginx-2", nginx, kapi.PodPending),
+		newPod("default", "nginx-3", nginx, kapi.PodRunning),
+		newPod("default", "other", map[string]string{"app": "other"}, kapi.PodRunning),
+		newPod("other", "nginx-4", nginx, kapi.PodRunning),
+	)
+
+	cases := []struct {
+		config   Config
+		expected []string
+	}{
+		{Config{NotifyExec: "nginx -s reload", NotifyExecSelector: "app=nginx", NotifyExecNamespace: "default"}, []string{"nginx-1", "nginx-3"}},
+		{Config{NotifyExec: "nginx -s reload", NotifyExecSelector: "app=nginx", NotifyExecNamespace: "other"}, []string{"nginx-4"}},
+		{Config{NotifyExec: "nginx -s reload", NotifyExecSelector: "app=missing", NotifyExecNamespace: "default"}, nil},
+	}
+
+	for i, c := range cases {
+		n, err := newExecNotifier(client, nil, c.config, slog.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pods, err := n.targets(context.Background()); err != nil || !reflect.DeepEqual(pods, c.expected) {
+			t.Errorf("case %d failed: got [%v, %v] expected [%v]\n", i, pods, err, c.expected)
+		}
+	}
+}
+
+func TestExecNotifierCurrentPod(t *testing.T) {
+	n, err := newExecNotifier(fake.NewSimpleClientset(), nil, Config{NotifyExec: "nginx -s reload", NotifyExecNamespace: "default"}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n.command, []string{"nginx", "-s", "reload"}) {
+		t.Errorf("unexpected command: %v", n.command)
+	}
+	if pods, err := n.targets(context.Background()); err != nil || len(pods) != 1 || pods[0] == "" {
+		t.Errorf("expected the current pod, got [%v, %v]", pods, err)
+	}
+}
+
+// newExecServer returns a client and config for a server that records the
+// exec requests it receives, and responds to them with handler
+func newExecServer(t *testing.T, handler http.HandlerFunc) (kclient.Interface, *krest.Config, *[]*http.Request) {
+	var (
+		mu   sync.Mutex
+		reqs []*http.Request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r)
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	config := &krest.Config{Host: srv.URL}
+	client, err := kclient.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, config, &reqs
+}
+
+func TestExecNotifierRequest(t *testing.T) {
+	client, config, reqs := newExecServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upgrade refused", http.StatusForbidden)
+	})
+	n, err := newExecNotifier(client, config, Config{
+		NotifyExec:          `sh -c "nginx -t && nginx -s reload"`,
+		NotifyExecContainer: "nginx",
+		NotifyExecNamespace: "web",
+	}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.exec(context.Background(), "nginx-1")
+	if err == nil || !strings.Contains(err.Error(), "error running command in pod web/nginx-1") {
+		t.Errorf("expected an error running the command, got [%v]", err)
+	}
+	if len(*reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*reqs))
+	}
+
+	r := (*reqs)[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/api/v1/namespaces/web/pods/nginx-1/exec" {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+	expected := url.Values{
+		"container": {"nginx"},
+		"command":   {"sh", "-c", "nginx -t && nginx -s reload"},
+		"stdout":    {"true"},
+		"stderr":    {"true"},
+	}
+	if q := r.URL.Query(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("expected query %v, got %v", expected, q)
+	}
+}
+
+func TestExecNotifierTimeout(t *testing.T) {
+	// the server never answers the upgrade request until the test ends
+	release := make(chan struct{})
+	client, config, _ := newExecServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	t.Cleanup(func() { close(release) })
+	n, err := newExecNotifier(client, config, Config{
+		NotifyExec:          "nginx -s reload",
+		NotifyExecNamespace: "web",
+		NotifyExecTimeout:   50 * time.Millisecond,
+	}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = n.exec(context.Background(), "nginx-1")
+	if err == nil || !strings.Contains(err.Error(), "error running command in pod web/nginx-1: timed out after 50ms") {
+		t.Errorf("expected a timeout error, got [%v]", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the command to be cancelled, took %v", elapsed)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+		err      bool
+	}{
+		{"nginx -s reload", []string{"nginx", "-s", "reload"}, false},
+		{"  nginx\t-s  reload ", []string{"nginx", "-s", "reload"}, false},
+		{`sh -c "nginx -t && nginx -s reload"`, []string{"sh", "-c", "nginx -t && nginx -s reload"}, false},
+		{`sh -c 'echo "$HOME"'`, []string{"sh", "-c", `echo "$HOME"`}, false},
+		{`echo "a \"b\" \c" d\ e ''`, []string{"echo", `a "b" \c`, "d e", ""}, false},
+		{`echo 'a\b'`, []string{"echo", `a\b`}, false},
+		{"", nil, false},
+		{`sh -c "nginx`, nil, true},
+		{`echo \`, nil, true},
+	}
+
+	for _, c := range cases {
+		args, err := splitCommand(c.input)
+		if (err != nil) != c.err || !reflect.DeepEqual(args, c.expected) {
+			t.Errorf("splitCommand(%q) = %q, %v, expected %q", c.input, args, err, c.expected)
+		}
+	}
+}
+
+// fakeConnection is an httpstream.Connection that records when it is closed
+type fakeConnection struct {
+	httpstream.Connection
+	closed chan bool
+}
+
+func (c *fakeConnection) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func (c *fakeConnection) CloseChan() <-chan bool {
+	return c.closed
+}
+
+type fakeUpgrader struct {
+	conn httpstream.Connection
+}
+
+func (u fakeUpgrader) NewConnection(*http.Response) (httpstream.Connection, error) {
+	return u.conn, nil
+}
+
+func TestContextUpgrader(t *testing.T) {
+	conn := &fakeConnection{closed: make(chan bool)}
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &contextUpgrader{fakeUpgrader{conn}, ctx}
+	if _, err := u.NewConnection(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case <-conn.closed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the connection to be closed when the context is done")
+	}
+}
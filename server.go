@@ -0,0 +1,93 @@
+package kubegen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// default time a render may run before the liveness check fails
+const defaultRenderTimeout = 5 * time.Minute
+
+// startServers starts HTTP servers for the metrics and health check
+// endpoints, which are shut down when ctx is cancelled. Endpoints configured
+// with the same address share a server.
+func (g *generator) startServers(ctx context.Context) error {
+	muxes := map[string]*http.ServeMux{}
+	mux := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	if g.Config.MetricsAddr != "" {
+		mux(g.Config.MetricsAddr).Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	}
+	if g.Config.HealthAddr != "" {
+		m := mux(g.Config.HealthAddr)
+		m.HandleFunc("/healthz", g.healthz)
+		m.HandleFunc("/readyz", g.readyz)
+	}
+
+	for addr, m := range muxes {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("error starting server: %w", err)
+		}
+		g.logger().Info("listening", "addr", ln.Addr().String())
+		srv := &http.Server{Handler: m}
+		g.Add(1)
+		go func() {
+			defer g.Done()
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				g.logger().Error("error serving http", "error", err)
+			}
+		}()
+		g.Add(1)
+		go func() {
+			defer g.Done()
+			<-ctx.Done()
+			srv.Shutdown(context.Background()) //nolint:errcheck
+		}()
+	}
+	return nil
+}
+
+// healthz reports whether the render goroutine is making progress. It fails
+// if a single render (including pre/post commands) has been running for
+// longer than the render timeout.
+func (g *generator) healthz(w http.ResponseWriter, r *http.Request) {
+	timeout := g.Config.RenderTimeout
+	if timeout <= 0 {
+		timeout = defaultRenderTimeout
+	}
+	if start := g.renderStart.Load(); start != 0 {
+		if d := time.Since(time.Unix(0, start)); d > timeout {
+			http.Error(w, fmt.Sprintf("render has been running for %v", d.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether all informers have synced and the output has been
+// rendered at least once. Followers are ready once their caches have synced.
+func (g *generator) readyz(w http.ResponseWriter, r *http.Request) {
+	for _, synced := range g.synced {
+		if !synced() {
+			http.Error(w, "waiting for informers to sync", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if g.isLeader() && !g.rendered.Load() {
+		http.Error(w, "waiting for the first render", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,42 @@
+package kubegen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func checkStatus(t *testing.T, name string, handler http.HandlerFunc, expected int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != expected {
+		t.Errorf("%s: expected status %d, got %d: %s", name, expected, w.Code, w.Body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	var synced bool
+	g := &generator{}
+	g.synced = append(g.synced, func() bool { return true }, func() bool { return synced })
+
+	checkStatus(t, "not synced", g.readyz, http.StatusServiceUnavailable)
+
+	synced = true
+	checkStatus(t, "not rendered", g.readyz, http.StatusServiceUnavailable)
+
+	g.rendered.Store(true)
+	checkStatus(t, "ready", g.readyz, http.StatusOK)
+}
+
+func TestHealthz(t *testing.T) {
+	g := &generator{Config: Config{RenderTimeout: time.Minute}}
+	checkStatus(t, "idle", g.healthz, http.StatusOK)
+
+	g.renderStart.Store(time.Now().Add(-30 * time.Second).UnixNano())
+	checkStatus(t, "rendering", g.healthz, http.StatusOK)
+
+	g.renderStart.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	checkStatus(t, "wedged", g.healthz, http.StatusServiceUnavailable)
+}
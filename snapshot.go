@@ -0,0 +1,77 @@
+package kubegen
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	kapi "k8s.io/api/core/v1"
+)
+
+const snapshotVersion = 1
+
+// snapshot is the format of the gzipped JSON files written by Snapshot
+type snapshot struct {
+	Version   int              `json:"version"`
+	Time      time.Time        `json:"time"`
+	Pods      []kapi.Pod       `json:"pods"`
+	Services  []kapi.Service   `json:"services"`
+	Endpoints []kapi.Endpoints `json:"endpoints"`
+}
+
+// Snapshot writes the objects a render would load to w as gzipped JSON. The
+// snapshot can be rendered later using the Replay option.
+func (g *generator) Snapshot(w io.Writer) error {
+	if err := g.validateConfig(); err != nil {
+		return err
+	}
+	ctx, err := g.loadContext(context.Background())
+	if err != nil {
+		return err
+	}
+	return writeSnapshot(w, ctx)
+}
+
+func writeSnapshot(w io.Writer, ctx *Context) error {
+	s := snapshot{
+		Version:   snapshotVersion,
+		Time:      time.Now().UTC(),
+		Pods:      ctx.Pods,
+		Services:  ctx.Services,
+		Endpoints: ctx.Endpoints,
+	}
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// loadSnapshot reads a Context from a snapshot file
+func loadSnapshot(path string) (*Context, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading snapshot: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error loading snapshot %s: %w", path, err)
+	}
+	var s snapshot
+	if err := json.NewDecoder(zr).Decode(&s); err != nil {
+		return nil, fmt.Errorf("error loading snapshot %s: %w", path, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("error loading snapshot %s: unsupported version %d", path, s.Version)
+	}
+	return &Context{Pods: s.Pods, Services: s.Services, Endpoints: s.Endpoints}, nil
+}
@@ -0,0 +1,92 @@
+package kubegen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSnapshotReplay(t *testing.T) {
+	pod1 := newPod("default", "pod-1", nil, kapi.PodRunning)
+	pod1.Spec.NodeName = "node-a"
+	pod2 := newPod("default", "pod-2", nil, kapi.PodRunning)
+	pod2.Spec.NodeName = "node-b"
+	g := &generator{
+		Config: Config{Node: "node-a"},
+		Client: fake.NewSimpleClientset(
+			pod1,
+			pod2,
+			&kapi.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"}},
+			&kapi.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"}},
+		),
+		loadPods: true,
+		loadSvcs: true,
+	}
+
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	snap := filepath.Join(dir, "snapshot.json.gz")
+	if err := os.WriteFile(snap, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	replay, err := NewGenerator(Config{
+		Replay:         snap,
+		TemplateString: `{{ range .Pods }}{{ .Name }} {{ end }}{{ len .Services }} {{ len .Endpoints }}`,
+		Output:         out,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := replay.Generate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the node filter and resource types are applied when taking the snapshot
+	if b, _ := os.ReadFile(out); string(b) != "pod-1 1 0" {
+		t.Errorf("expected output [pod-1 1 0], got [%s]", b)
+	}
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s)) //nolint:errcheck
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{filepath.Join(dir, "missing"), "error loading snapshot: "},
+		{write("plain", []byte(`{"version":1}`)), "error loading snapshot " + dir + "/plain: gzip: invalid header"},
+		{write("version", gzipped(`{"version":2}`)), "error loading snapshot " + dir + "/version: unsupported version 2"},
+	}
+
+	for i, c := range cases {
+		if _, err := loadSnapshot(c.path); err == nil || !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("case %d failed: got [%v] expected an error starting with [%s]", i, err, c.expected)
+		}
+	}
+}
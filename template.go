@@ -10,9 +10,19 @@ func newTemplate(name string) *template.Template {
 	return template.New(name).Funcs(Funcs)
 }
 
+// Parses a template located at path
+func parseTemplateFile(path string) (*template.Template, error) {
+	return newTemplate(filepath.Base(path)).ParseFiles(path)
+}
+
+// Parses a template string
+func parseTemplateString(text string) (*template.Template, error) {
+	return newTemplate("stdin").Parse(text)
+}
+
 // Executes a template located at path with the specified data
 func execTemplateFile(path string, data any) ([]byte, error) {
-	tmpl, err := newTemplate(filepath.Base(path)).ParseFiles(path)
+	tmpl, err := parseTemplateFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -21,7 +31,7 @@ func execTemplateFile(path string, data any) ([]byte, error) {
 
 // Executes a template string with the specified data
 func execTemplateString(text string, data any) ([]byte, error) {
-	tmpl, err := newTemplate("stdin").Parse(text)
+	tmpl, err := parseTemplateString(text)
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +62,55 @@ var Funcs = template.FuncMap{
 	"whereAll":      whereAll,
 }
 
+// FuncDescriptions describes each of the functions in Funcs
+var FuncDescriptions = map[string]string{
+	"add":           "Returns the sum of two integers",
+	"allPodsReady":  "Returns true if every pod in a list of pods is ready",
+	"anyPodReady":   "Returns true if at least one pod in a list of pods is ready",
+	"closest":       "Returns the longest string in a list that is contained in the input string",
+	"coalesce":      "Returns the first argument that is not nil",
+	"combine":       "Combines multiple slices into a single slice",
+	"dir":           "Returns the names of the files in a directory",
+	"exists":        "Returns true if a path exists on the local filesystem",
+	"first":         "Returns the first item of a slice, or nil if it is empty",
+	"groupBy":       "Groups a slice of objects by the value of the given field, e.g. groupBy .Pods \"Spec.NodeName\"",
+	"groupByKeys":   "Returns the distinct values of the given field in a slice of objects, i.e. the keys of groupBy",
+	"groupByMulti":  "Like groupBy, but splits the value of the field using a separator. Objects are added to the group of each value",
+	"hasPrefix":     "Returns true if a string begins with a prefix",
+	"hasSuffix":     "Returns true if a string ends with a suffix",
+	"hasField":      "Returns true if an object has the given field",
+	"intersect":     "Returns the strings found in both of two lists",
+	"isPodReady":    "Returns true if a pod is ready",
+	"isValidJson":   "Returns true if a string is valid JSON",
+	"json":          "Marshals a value to JSON",
+	"pathJoin":      "Joins path elements into a single path",
+	"pathJoinSlice": "Joins a slice of path elements into a single path",
+	"keys":          "Returns the keys of a map",
+	"last":          "Returns the last item of a slice, or nil if it is empty",
+	"dict":          "Creates a map from a list of key/value pairs, e.g. dict \"name\" .Name \"port\" 80",
+	"mapContains":   "Returns true if a map contains the given key",
+	"parseBool":     "Parses a string as a boolean",
+	"parseJson":     "Unmarshals a JSON string, failing if it is invalid",
+	"parseJsonSafe": "Unmarshals a JSON string, returning nil if it is invalid",
+	"readyPods":     "Returns the pods in a list of pods that are ready",
+	"replace":       "Replaces the first n occurrences of a string with another, or all occurrences if n is -1",
+	"shell":         "Runs a command using the shell and returns its result (.Success, .Stdout, .Stderr)",
+	"slice":         "Returns the items of a slice from begin (inclusive) to end (exclusive)",
+	"split":         "Splits a string using a separator",
+	"splitN":        "Splits a string using a separator into at most n parts",
+	"strContains":   "Returns true if a string contains a substring",
+	"trim":          "Removes leading and trailing whitespace from a string",
+	"trimPrefix":    "Removes a prefix from a string",
+	"trimSuffix":    "Removes a suffix from a string",
+	"values":        "Returns the values of a map",
+	"when":          "Returns the second argument if the condition is true, and the third otherwise",
+	"where":         "Returns the objects in a slice where the given field is equal to a value",
+	"whereExist":    "Returns the objects in a slice that have the given field",
+	"whereNotExist": "Returns the objects in a slice that don't have the given field",
+	"whereAny":      "Returns the objects in a slice where the given field, split using a separator, contains any of a list of values",
+	"whereAll":      "Returns the objects in a slice where the given field, split using a separator, contains all of a list of values",
+}
+
 func pathJoinSlice(input []string) string {
 	return filepath.Join(input...)
 }
@@ -0,0 +1,16 @@
+package kubegen
+
+import "testing"
+
+func TestFuncDescriptions(t *testing.T) {
+	for name := range Funcs {
+		if FuncDescriptions[name] == "" {
+			t.Errorf("missing description of %s", name)
+		}
+	}
+	for name := range FuncDescriptions {
+		if _, ok := Funcs[name]; !ok {
+			t.Errorf("description of unknown function %s", name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package kubegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// files making up a template test case
+const (
+	testTemplateFile = "template.tmpl"
+	testManifestsDir = "manifests"
+	testExpectedFile = "expected.golden"
+)
+
+// TemplateTestResult is the result of a single template test case
+type TemplateTestResult struct {
+	// Name is the path of the case relative to the test directory
+	Name string
+	// Passed is true if the output matched the expected output
+	Passed bool
+	// Updated is true if the expected output was rewritten
+	Updated bool
+	// Diff describes the differences between the expected (-) and actual
+	// (+) output when the case failed
+	Diff string
+	// Err is set if the case could not be run
+	Err error
+}
+
+// RunTemplateTests runs the template test cases found in dir. Each directory
+// containing a template.tmpl file is a case: the template is rendered with
+// the objects in the manifests directory next to it, and the output is
+// compared with the contents of expected.golden. If update is true, the
+// expected output of failing cases is rewritten instead.
+func RunTemplateTests(dir string, update bool) ([]TemplateTestResult, error) {
+	var cases []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == testTemplateFile {
+			cases = append(cases, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding test cases: %w", err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no test cases found in %s", dir)
+	}
+	sort.Strings(cases)
+
+	results := make([]TemplateTestResult, len(cases))
+	for i, c := range cases {
+		name, err := filepath.Rel(dir, c)
+		if err != nil {
+			name = c
+		}
+		results[i] = runTemplateTest(c, update)
+		results[i].Name = filepath.ToSlash(name)
+	}
+	return results, nil
+}
+
+func runTemplateTest(dir string, update bool) TemplateTestResult {
+	var r TemplateTestResult
+
+	ctx := &Context{}
+	manifests := filepath.Join(dir, testManifestsDir)
+	if _, err := os.Stat(manifests); err == nil {
+		if ctx, err = loadManifests([]string{manifests}); err != nil {
+			r.Err = err
+			return r
+		}
+	}
+
+	tmpl, err := parseTemplateFile(filepath.Join(dir, testTemplateFile))
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	got, err := execTemplate(tmpl, ctx)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	expectedPath := filepath.Join(dir, testExpectedFile)
+	expected, err := os.ReadFile(expectedPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !update:
+		r.Err = fmt.Errorf("missing %s. Run with update to create it", testExpectedFile)
+		return r
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		r.Err = err
+		return r
+	}
+
+	if err == nil && bytes.Equal(got, expected) {
+		r.Passed = true
+		return r
+	}
+	if update {
+		if r.Err = os.WriteFile(expectedPath, got, 0o644); r.Err == nil {
+			r.Passed, r.Updated = true, true
+		}
+		return r
+	}
+	r.Diff = cmp.Diff(strings.SplitAfter(string(expected), "\n"), strings.SplitAfter(string(got), "\n"))
+	return r
+}
@@ -0,0 +1,90 @@
+package kubegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestCase creates a test case in dir with the given template and
+// expected output. The expected output is not created if it is empty.
+func writeTestCase(t *testing.T, dir, tmpl, expected string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, testTemplateFile), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if expected != "" {
+		if err := os.WriteFile(filepath.Join(dir, testExpectedFile), []byte(expected), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunTemplateTests(t *testing.T) {
+	results, err := RunTemplateTests("testdata/cases", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "upstreams" || !results[0].Passed {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestRunTemplateTestsFailures(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCase(t, filepath.Join(dir, "diff"), "a\nb\n", "a\nc\n")
+	writeTestCase(t, filepath.Join(dir, "error"), "{{ .Missing }}", "x")
+	writeTestCase(t, filepath.Join(dir, "missing"), "a", "")
+	writeTestCase(t, filepath.Join(dir, "pass"), "{{ len .Pods }}", "0")
+
+	results, err := RunTemplateTests(dir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %+v", results)
+	}
+
+	if r := results[0]; r.Name != "diff" || r.Passed || !strings.Contains(r.Diff, `"c\n"`) || !strings.Contains(r.Diff, `"b\n"`) {
+		t.Errorf("unexpected result for diff: %+v", r)
+	}
+	if r := results[1]; r.Name != "error" || r.Err == nil || !strings.Contains(r.Err.Error(), "can't evaluate field Missing") {
+		t.Errorf("unexpected result for error: %+v", r)
+	}
+	if r := results[2]; r.Name != "missing" || r.Err == nil || !strings.HasPrefix(r.Err.Error(), "missing expected.golden") {
+		t.Errorf("unexpected result for missing: %+v", r)
+	}
+	if r := results[3]; r.Name != "pass" || !r.Passed || r.Updated {
+		t.Errorf("unexpected result for pass: %+v", r)
+	}
+}
+
+func TestRunTemplateTestsUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCase(t, filepath.Join(dir, "diff"), "new", "old")
+	writeTestCase(t, filepath.Join(dir, "missing"), "new", "")
+
+	results, err := RunTemplateTests(dir, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range results {
+		if !r.Passed || !r.Updated {
+			t.Errorf("expected %s to be updated: %+v", r.Name, r)
+		}
+		if b, _ := os.ReadFile(filepath.Join(dir, r.Name, testExpectedFile)); string(b) != "new" {
+			t.Errorf("expected %s to contain [new], got [%s]", r.Name, b)
+		}
+	}
+}
+
+func TestRunTemplateTestsNoCases(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := RunTemplateTests(dir, false); err == nil || err.Error() != "no test cases found in "+dir {
+		t.Errorf("expected no test cases error, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ package kubegen
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"syscall"
 )
 
@@ -13,6 +14,17 @@ const (
 	shellArg = "-c"
 )
 
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"TERM":  syscall.SIGTERM,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+}
+
 func setFileModeAndOwnership(f *os.File, fi os.FileInfo) error {
 	if err := f.Chmod(fi.Mode()); err != nil {
 		return fmt.Errorf("error setting file permissions: %w", err)
@@ -35,3 +47,12 @@ func moveFile(src *os.File, dest string) error {
 	}
 	return nil
 }
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group started by cmd
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
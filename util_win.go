@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"syscall"
 )
 
 const (
@@ -13,6 +14,14 @@ const (
 	shellArg = "/c"
 )
 
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
 func setFileModeAndOwnership(f *os.File, fi os.FileInfo) error {
 	return nil
 }
@@ -25,3 +34,9 @@ func moveFile(src *os.File, dest string) error {
 	}
 	return nil
 }
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
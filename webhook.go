@@ -0,0 +1,160 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultNotifyMethod  = http.MethodPost
+	defaultNotifyBackoff = time.Second
+	notifyTimeout        = 10 * time.Second
+)
+
+// webhookNotifier sends an HTTP request after the output has been written.
+// The method, body and header values are templates executed against the
+// renderResult.
+type webhookNotifier struct {
+	url     string
+	method  *template.Template
+	headers map[string]*template.Template
+	body    *template.Template
+	retries int
+	backoff time.Duration
+	client  *http.Client
+	log     *slog.Logger
+}
+
+func newWebhookNotifier(url string, c Config, log *slog.Logger) (*webhookNotifier, error) {
+	n := &webhookNotifier{
+		url:     url,
+		headers: make(map[string]*template.Template, len(c.NotifyHeaders)),
+		retries: c.NotifyRetries,
+		backoff: c.NotifyBackoff,
+		client:  &http.Client{Timeout: notifyTimeout},
+		log:     log,
+	}
+	method := c.NotifyMethod
+	if method == "" {
+		method = defaultNotifyMethod
+	}
+	t, err := newTemplate("method").Parse(method)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing notification method: %w", err)
+	}
+	n.method = t
+	if n.backoff <= 0 {
+		n.backoff = defaultNotifyBackoff
+	}
+
+	for _, h := range c.NotifyHeaders {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid notification header [%s]: expected <name>: <value>", h)
+		}
+		t, err := newTemplate("header").Parse(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing notification header: %w", err)
+		}
+		n.headers[strings.TrimSpace(k)] = t
+	}
+
+	if c.NotifyBody != "" {
+		t, err := newTemplate("body").Parse(c.NotifyBody)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing notification body: %w", err)
+		}
+		n.body = t
+	}
+	return n, nil
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, r *renderResult) error {
+	m, err := execTemplate(n.method, r)
+	if err != nil {
+		return fmt.Errorf("error rendering notification method: %w", err)
+	}
+	method := strings.TrimSpace(string(m))
+	if method == "" {
+		return errors.New("notification method is empty")
+	}
+
+	var body []byte
+	if n.body != nil {
+		body, err = execTemplate(n.body, r)
+	} else {
+		body, err = json.Marshal(r)
+	}
+	if err != nil {
+		return fmt.Errorf("error rendering notification body: %w", err)
+	}
+
+	headers := make(http.Header, len(n.headers))
+	for k, t := range n.headers {
+		v, err := execTemplate(t, r)
+		if err != nil {
+			return fmt.Errorf("error rendering notification header: %w", err)
+		}
+		headers.Set(k, string(v))
+	}
+	if n.body == nil && headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/json")
+	}
+
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.send(ctx, method, headers, body)
+		if err == nil {
+			n.log.Info("sent notification", "url", n.url)
+			return nil
+		}
+		if !retry || attempt >= n.retries || ctx.Err() != nil {
+			return err
+		}
+		n.log.Warn("notification failed. retrying", "url", n.url, "error", err, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// send sends a notification request, returning whether it may succeed if
+// it's retried when it fails
+func (n *webhookNotifier) send(ctx context.Context, method string, headers http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error creating notification request: %w", err)
+	}
+	req.Header = headers.Clone()
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("error sending notification to [%s]: %w", n.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return retryableStatus(resp.StatusCode), fmt.Errorf("error sending notification to [%s]: %s", n.url, resp.Status)
+	}
+	return false, nil
+}
+
+// retryableStatus returns true if a request that failed with the status code
+// may succeed later. Other errors, such as 404, need the configuration to be
+// fixed.
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
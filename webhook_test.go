@@ -0,0 +1,199 @@
+package kubegen
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type webhookRequest struct {
+	method  string
+	headers http.Header
+	body    string
+}
+
+// newWebhookServer returns a server that records requests and fails the
+// first failures requests it receives
+func newWebhookServer(t *testing.T, failures int) (*httptest.Server, *[]webhookRequest) {
+	return newWebhookServerStatus(t, failures, http.StatusServiceUnavailable)
+}
+
+// newWebhookServerStatus returns a server that responds to the first failures
+// requests with status
+func newWebhookServerStatus(t *testing.T, failures, status int) (*httptest.Server, *[]webhookRequest) {
+	var reqs []webhookRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, webhookRequest{r.Method, r.Header, string(b)})
+		if len(reqs) <= failures {
+			w.WriteHeader(status)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	srv, reqs := newWebhookServer(t, 0)
+
+	n, err := newWebhookNotifier(srv.URL, Config{
+		NotifyMethod:  http.MethodPut,
+		NotifyHeaders: []string{"X-Checksum: {{ .Checksum }}", "Content-Type: text/plain"},
+		NotifyBody:    "{{ .Output }} changed",
+	}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newRenderResult("/etc/nginx/nginx.conf", []byte("content"))
+	if err := n.notify(context.Background(), r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(*reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*reqs))
+	}
+	req := (*reqs)[0]
+	if req.method != http.MethodPut {
+		t.Errorf("expected method PUT, got %s", req.method)
+	}
+	if v := req.headers.Get("X-Checksum"); v != r.Checksum {
+		t.Errorf("expected checksum header [%s], got [%s]", r.Checksum, v)
+	}
+	if v := req.headers.Get("Content-Type"); v != "text/plain" {
+		t.Errorf("expected content type [text/plain], got [%s]", v)
+	}
+	if expected := "/etc/nginx/nginx.conf changed"; req.body != expected {
+		t.Errorf("expected body [%s], got [%s]", expected, req.body)
+	}
+}
+
+func TestWebhookNotifierDefaultBody(t *testing.T) {
+	srv, reqs := newWebhookServer(t, 0)
+
+	n, err := newWebhookNotifier(srv.URL, Config{}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &renderResult{Output: "out", Checksum: "abc", Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := n.notify(context.Background(), r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := (*reqs)[0]
+	if req.method != http.MethodPost {
+		t.Errorf("expected method POST, got %s", req.method)
+	}
+	if v := req.headers.Get("Content-Type"); v != "application/json" {
+		t.Errorf("expected content type [application/json], got [%s]", v)
+	}
+	if expected := `{"output":"out","checksum":"abc","changed":false,"time":"2020-01-02T03:04:05Z"}`; req.body != expected {
+		t.Errorf("expected body [%s], got [%s]", expected, req.body)
+	}
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	cases := []struct {
+		status   int
+		failures int
+		retries  int
+		requests int
+		success  bool
+	}{
+		{http.StatusServiceUnavailable, 0, 3, 1, true},
+		{http.StatusServiceUnavailable, 2, 3, 3, true},
+		{http.StatusServiceUnavailable, 3, 3, 4, true},
+		{http.StatusServiceUnavailable, 4, 3, 4, false},
+		{http.StatusServiceUnavailable, 1, 0, 1, false},
+		{http.StatusInternalServerError, 1, 3, 2, true},
+		{http.StatusRequestTimeout, 1, 3, 2, true},
+		{http.StatusTooManyRequests, 1, 3, 2, true},
+		{http.StatusBadRequest, 1, 3, 1, false},
+		{http.StatusUnauthorized, 1, 3, 1, false},
+		{http.StatusForbidden, 1, 3, 1, false},
+		{http.StatusNotFound, 1, 3, 1, false},
+	}
+
+	for i, c := range cases {
+		srv, reqs := newWebhookServerStatus(t, c.failures, c.status)
+		n, err := newWebhookNotifier(srv.URL, Config{NotifyRetries: c.retries, NotifyBackoff: time.Millisecond}, slog.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = n.notify(context.Background(), newRenderResult("", nil))
+		if (err == nil) != c.success {
+			t.Errorf("case %d failed: got error [%v], expected success: %v", i, err, c.success)
+		}
+		if len(*reqs) != c.requests {
+			t.Errorf("case %d failed: got %d requests, expected %d", i, len(*reqs), c.requests)
+		}
+	}
+
+	// connection errors are retried
+	srv, _ := newWebhookServer(t, 0)
+	srv.Close()
+	n, err := newWebhookNotifier(srv.URL, Config{NotifyRetries: 2, NotifyBackoff: time.Millisecond}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n.log = slog.New(slog.NewTextHandler(&buf, nil))
+	if err := n.notify(context.Background(), newRenderResult("", nil)); err == nil {
+		t.Errorf("expected an error")
+	}
+	if retries := strings.Count(buf.String(), "retrying"); retries != 2 {
+		t.Errorf("expected 2 retries, got %d: %s", retries, buf.String())
+	}
+}
+
+func TestWebhookNotifierMethodTemplate(t *testing.T) {
+	srv, reqs := newWebhookServer(t, 0)
+	n, err := newWebhookNotifier(srv.URL, Config{NotifyMethod: "{{ if .Changed }}PUT{{ else }}PATCH{{ end }}"}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, changed := range []bool{true, false} {
+		if err := n.notify(context.Background(), &renderResult{Changed: changed}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(*reqs) != 2 || (*reqs)[0].method != http.MethodPut || (*reqs)[1].method != http.MethodPatch {
+		t.Errorf("expected PUT and PATCH requests, got %+v", *reqs)
+	}
+}
+
+func TestWebhookNotifierCancel(t *testing.T) {
+	srv, reqs := newWebhookServer(t, 10)
+	n, err := newWebhookNotifier(srv.URL, Config{NotifyRetries: 3, NotifyBackoff: time.Minute}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.notify(ctx, newRenderResult("", nil)); err == nil {
+		t.Errorf("expected an error")
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("expected cancelling the context to stop retrying, waited %v", d)
+	}
+	if len(*reqs) != 1 {
+		t.Errorf("expected 1 request, got %d", len(*reqs))
+	}
+}
+
+func TestWebhookNotifierInvalidHeader(t *testing.T) {
+	if _, err := newWebhookNotifier("http://localhost", Config{NotifyHeaders: []string{"invalid"}}, slog.Default()); err == nil {
+		t.Errorf("expected an error for an invalid header")
+	}
+}